@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/ViBiOh/flags"
+	"github.com/ViBiOh/viws/pkg/dev"
+	"github.com/ViBiOh/viws/pkg/env"
+	"github.com/ViBiOh/viws/pkg/metrics"
+	"github.com/ViBiOh/viws/pkg/viws"
+)
+
+const checkCommand = "check"
+
+// check lints a directory before deploy with the same options as the server, e.g. `viws-light check -spa ./dist`, and
+// returns the exit code: non zero when errors are found.
+func check(args []string) int {
+	fs := flag.NewFlagSet("viws", flag.ExitOnError)
+	fs.Usage = flags.Usage(fs)
+
+	config := viws.Flags(fs, "")
+	envConfig := env.Flags(fs, "")
+	metricsConfig := metrics.Flags(fs, "")
+	asJSON := flags.New("Json", "Output report as JSON").DocPrefix("check").Bool(fs, false, nil)
+
+	_ = fs.Parse(args)
+
+	// Flags are also accepted after the directory, e.g. `viws-light check ./dist -spa`
+	var directories []string
+	for fs.NArg() != 0 {
+		directories = append(directories, fs.Arg(0))
+		_ = fs.Parse(fs.Args()[1:])
+	}
+
+	if len(directories) > 1 {
+		fmt.Fprintf(os.Stderr, "check: expecting a single directory, got %d\n", len(directories))
+		return 2
+	}
+
+	if len(directories) != 0 {
+		config.Directory = directories[0]
+	}
+
+	// Only problems are of interest, not the startup logs
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+
+	endpoints, err := checkEndpoints(envConfig, metricsConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "endpoints: %s\n", err)
+		return 2
+	}
+
+	report, err := viws.Check(config, endpoints, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check: %s\n", err)
+		return 2
+	}
+
+	if err := report.Write(os.Stdout, *asJSON); err != nil {
+		fmt.Fprintf(os.Stderr, "write report: %s\n", err)
+		return 2
+	}
+
+	if report.Errors() != 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// checkEndpoints lists paths answered by the server itself, that links of the site can target without a file.
+func checkEndpoints(envConfig *env.Config, metricsConfig *metrics.Config) ([]string, error) {
+	envServices, err := env.NewEndpoints(envConfig)
+	if err != nil {
+		return nil, fmt.Errorf("env: %w", err)
+	}
+
+	endpoints := []string{"/health", "/ready", "/version", dev.EventsPath}
+
+	for _, envService := range envServices {
+		if len(envService.Path()) != 0 {
+			endpoints = append(endpoints, envService.Path())
+		}
+	}
+
+	if registry := metrics.New(metricsConfig); len(registry.Path()) != 0 && !registry.Separate() {
+		endpoints = append(endpoints, registry.Path())
+	}
+
+	return endpoints, nil
+}
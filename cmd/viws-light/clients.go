@@ -16,7 +16,5 @@ func newClients(ctx context.Context, config configuration) clients {
 
 	logger.Init(ctx, config.logger)
 
-	output.health = health.New(ctx, config.health)
-
 	return output
 }
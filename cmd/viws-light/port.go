@@ -5,13 +5,35 @@ import (
 
 	"github.com/ViBiOh/httputils/v4/pkg/httputils"
 	"github.com/ViBiOh/httputils/v4/pkg/model"
+	"github.com/ViBiOh/viws/pkg/dev"
 )
 
 func newPort(clients clients, services services) http.Handler {
 	mux := http.NewServeMux()
 
-	mux.Handle("GET /env", model.ChainMiddlewares(services.env.Handler(), services.owasp.Middleware, services.cors.Middleware))
-	mux.Handle("GET /", model.ChainMiddlewares(services.viws.Handler(), services.owasp.Middleware, services.cors.Middleware))
+	for _, envService := range services.envs {
+		if len(envService.Path()) != 0 {
+			mux.Handle("GET "+envService.Path(), model.ChainMiddlewares(envService.Handler(), services.owasp.Middleware, services.cors.Middleware))
+		}
+	}
 
-	return httputils.Handler(mux, clients.health)
+	if len(services.metrics.Path()) != 0 && !services.metrics.Separate() {
+		mux.Handle("GET "+services.metrics.Path(), services.metrics.Handler())
+	}
+
+	if services.dev != nil {
+		mux.Handle("GET "+dev.EventsPath, services.dev.Handler())
+	}
+
+	// Every method is routed to files, which answer OPTIONS and 405 with the allowed methods
+	mux.Handle("/", model.ChainMiddlewares(services.viws.Handler(), services.owasp.Middleware, services.cors.Middleware))
+
+	return services.accessLog.Middleware(services.releases.VersionMiddleware(httputils.Handler(services.ipFilter.Middleware(services.rateLimit.Middleware(mux)), clients.health, services.compress.Middleware)))
+}
+
+func newMetricsPort(services services) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+services.metrics.Path(), services.metrics.Handler())
+
+	return mux
 }
@@ -1,31 +1,95 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/ViBiOh/httputils/v4/pkg/cors"
 	"github.com/ViBiOh/httputils/v4/pkg/owasp"
 	"github.com/ViBiOh/httputils/v4/pkg/server"
+	"github.com/ViBiOh/viws/pkg/accesslog"
+	"github.com/ViBiOh/viws/pkg/clientip"
+	"github.com/ViBiOh/viws/pkg/compress"
+	"github.com/ViBiOh/viws/pkg/dev"
 	"github.com/ViBiOh/viws/pkg/env"
+	"github.com/ViBiOh/viws/pkg/ipfilter"
+	"github.com/ViBiOh/viws/pkg/metrics"
+	"github.com/ViBiOh/viws/pkg/ratelimit"
+	"github.com/ViBiOh/viws/pkg/release"
 	"github.com/ViBiOh/viws/pkg/viws"
 )
 
 type services struct {
-	server *server.Server
-	cors   cors.Service
-	owasp  owasp.Service
+	server        *server.Server
+	metricsServer *server.Server
+	cors          cors.Service
+	owasp         owasp.Service
 
-	env  env.Service
-	viws viws.App
+	metrics   *metrics.Registry
+	compress  *compress.Service
+	accessLog *accesslog.Service
+	clientIP  clientip.Resolver
+	rateLimit *ratelimit.Service
+	ipFilter  *ipfilter.Service
+	dev       *dev.Service
+	releases  *release.Service
+	envs      []env.Service
+	viws      viws.App
 }
 
-func newServices(config configuration) services {
+func newServices(config configuration) (services, error) {
 	var output services
+	var err error
 
 	output.server = server.New(config.server)
+	output.metricsServer = server.New(config.metricsHTTP)
 	output.owasp = owasp.New(config.owasp)
 	output.cors = cors.New(config.cors)
 
-	output.env = env.New(config.env)
-	output.viws = viws.New(config.viws)
+	output.metrics = metrics.New(config.metrics)
+	output.compress, err = compress.New(config.compress, output.metrics)
+	if err != nil {
+		return output, fmt.Errorf("compress: %w", err)
+	}
+
+	output.clientIP, err = clientip.New(config.clientIP)
+	if err != nil {
+		return output, fmt.Errorf("client ip: %w", err)
+	}
+
+	output.accessLog, err = accesslog.New(config.accessLog, output.clientIP)
+	if err != nil {
+		return output, fmt.Errorf("access log: %w", err)
+	}
+
+	output.rateLimit, err = ratelimit.New(config.rateLimit, output.clientIP, output.metrics)
+	if err != nil {
+		return output, fmt.Errorf("rate limit: %w", err)
+	}
+
+	output.ipFilter, err = ipfilter.New(config.ipFilter, output.clientIP, output.metrics)
+	if err != nil {
+		return output, fmt.Errorf("ip filter: %w", err)
+	}
+
+	output.dev, err = dev.New(config.dev, config.viws.Directory, config.hsts)
+	if err != nil {
+		return output, fmt.Errorf("dev: %w", err)
+	}
+
+	output.envs, err = env.NewEndpoints(config.env)
+	if err != nil {
+		return output, fmt.Errorf("env: %w", err)
+	}
+
+	output.releases, err = release.New(config.release)
+	if err != nil {
+		return output, fmt.Errorf("release: %w", err)
+	}
+
+	output.viws, err = viws.New(config.viws, output.releases, output.metrics, output.dev, output.compress)
+	if err != nil {
+		return output, fmt.Errorf("viws: %w", err)
+	}
 
-	return output
+	return output, nil
 }
@@ -2,23 +2,58 @@ package main
 
 import (
 	"context"
+	"log/slog"
+	"os"
 
 	"github.com/ViBiOh/httputils/v4/pkg/alcotest"
 	"github.com/ViBiOh/httputils/v4/pkg/health"
+	"github.com/ViBiOh/httputils/v4/pkg/logger"
+	"github.com/ViBiOh/viws/pkg/dev"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == checkCommand {
+		os.Exit(check(os.Args[2:]))
+	}
+
 	config := newConfig()
 	alcotest.DoAndExit(config.alcotest)
 
 	ctx := context.Background()
 
 	clients := newClients(ctx, config)
-	services := newServices(config)
+	services, err := newServices(config)
+	logger.FatalfOnErr(ctx, err, "services")
+
+	// Readiness checks the served content, so health is created once services are
+	clients.health = health.New(ctx, config.health, services.viws.Ready)
+	defer func() {
+		if err := services.accessLog.Close(); err != nil {
+			slog.LogAttrs(ctx, slog.LevelError, "close access log", slog.Any("error", err))
+		}
+	}()
+
+	go services.releases.Start(clients.health.DoneCtx())
+	go services.accessLog.Start(clients.health.DoneCtx())
+	go services.ipFilter.Start(clients.health.DoneCtx())
+	go services.dev.Start(clients.health.DoneCtx())
+	go services.viws.Start(clients.health.DoneCtx())
+
 	port := newPort(clients, services)
 
 	go services.server.Start(clients.health.EndCtx(), port)
 
+	if services.dev != nil {
+		dev.Banner(os.Stderr, config.url)
+	}
+
+	dones := []<-chan struct{}{services.server.Done()}
+
+	if services.metrics.Separate() {
+		go services.metricsServer.Start(clients.health.EndCtx(), newMetricsPort(services))
+		dones = append(dones, services.metricsServer.Done())
+	}
+
 	clients.health.WaitForTermination(services.server.Done())
-	health.WaitAll(services.server.Done())
+	health.WaitAll(dones...)
 }
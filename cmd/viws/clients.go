@@ -34,8 +34,6 @@ func newClients(ctx context.Context, config configuration) (clients, error) {
 	service, version, env := output.telemetry.GetServiceVersionAndEnv()
 	output.pprof = pprof.New(config.pprof, service, version, env)
 
-	output.health = health.New(ctx, config.health)
-
 	return output, nil
 }
 
@@ -2,7 +2,9 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/ViBiOh/flags"
 	"github.com/ViBiOh/httputils/v4/pkg/alcotest"
@@ -13,23 +15,47 @@ import (
 	"github.com/ViBiOh/httputils/v4/pkg/pprof"
 	"github.com/ViBiOh/httputils/v4/pkg/server"
 	"github.com/ViBiOh/httputils/v4/pkg/telemetry"
+	"github.com/ViBiOh/viws/pkg/accesslog"
+	"github.com/ViBiOh/viws/pkg/admin"
+	"github.com/ViBiOh/viws/pkg/clientip"
+	"github.com/ViBiOh/viws/pkg/compress"
+	"github.com/ViBiOh/viws/pkg/dev"
 	"github.com/ViBiOh/viws/pkg/env"
+	"github.com/ViBiOh/viws/pkg/ipfilter"
+	"github.com/ViBiOh/viws/pkg/metrics"
+	"github.com/ViBiOh/viws/pkg/proxy"
+	"github.com/ViBiOh/viws/pkg/ratelimit"
+	"github.com/ViBiOh/viws/pkg/release"
 	"github.com/ViBiOh/viws/pkg/viws"
 )
 
 type configuration struct {
-	alcotest  *alcotest.Config
-	health    *health.Config
-	logger    *logger.Config
-	telemetry *telemetry.Config
-	pprof     *pprof.Config
-	server    *server.Config
-	owasp     *owasp.Config
-	cors      *cors.Config
-
-	viws *viws.Config
-	env  *env.Config
-	gzip *bool
+	alcotest    *alcotest.Config
+	health      *health.Config
+	logger      *logger.Config
+	telemetry   *telemetry.Config
+	pprof       *pprof.Config
+	server      *server.Config
+	adminHTTP   *server.Config
+	metricsHTTP *server.Config
+	owasp       *owasp.Config
+	cors        *cors.Config
+
+	viws      *viws.Config
+	release   *release.Config
+	admin     *admin.Config
+	env       *env.Config
+	metrics   *metrics.Config
+	accessLog *accesslog.Config
+	clientIP  *clientip.Config
+	rateLimit *ratelimit.Config
+	ipFilter  *ipfilter.Config
+	dev       *dev.Config
+	compress  *compress.Config
+	proxy     *proxy.Config
+
+	url  string
+	hsts bool
 }
 
 func newConfig() configuration {
@@ -37,21 +63,36 @@ func newConfig() configuration {
 	fs.Usage = flags.Usage(fs)
 
 	config := configuration{
-		health:    health.Flags(fs, ""),
-		alcotest:  alcotest.Flags(fs, ""),
-		logger:    logger.Flags(fs, "logger"),
-		telemetry: telemetry.Flags(fs, "telemetry"),
-		pprof:     pprof.Flags(fs, "pprof"),
-		server:    server.Flags(fs, ""),
-		owasp:     owasp.Flags(fs, ""),
-		cors:      cors.Flags(fs, "cors"),
-
-		viws: viws.Flags(fs, ""),
-		env:  env.Flags(fs, ""),
-		gzip: flags.New("Gzip", "Enable gzip compression").DocPrefix("gzip").Bool(fs, true, nil),
+		health:      health.Flags(fs, ""),
+		alcotest:    alcotest.Flags(fs, ""),
+		logger:      logger.Flags(fs, "logger"),
+		telemetry:   telemetry.Flags(fs, "telemetry"),
+		pprof:       pprof.Flags(fs, "pprof"),
+		server:      server.Flags(fs, ""),
+		adminHTTP:   server.Flags(fs, "admin", flags.NewOverride("Name", "admin"), flags.NewOverride("Port", uint(1081))),
+		metricsHTTP: server.Flags(fs, "metrics", flags.NewOverride("Name", "metrics"), flags.NewOverride("Port", uint(9090))),
+		owasp:       owasp.Flags(fs, ""),
+		cors:        cors.Flags(fs, "cors"),
+
+		viws:      viws.Flags(fs, ""),
+		release:   release.Flags(fs, ""),
+		admin:     admin.Flags(fs, "admin"),
+		env:       env.Flags(fs, ""),
+		metrics:   metrics.Flags(fs, ""),
+		accessLog: accesslog.Flags(fs, ""),
+		clientIP:  clientip.Flags(fs, ""),
+		rateLimit: ratelimit.Flags(fs, ""),
+		ipFilter:  ipfilter.Flags(fs, ""),
+		dev:       dev.Flags(fs, ""),
+		compress:  compress.Flags(fs, ""),
+		proxy:     proxy.Flags(fs, ""),
 	}
 
 	_ = fs.Parse(os.Args[1:])
 
+	// Read from flags, as they are registered by owasp and server packages
+	config.hsts, _ = strconv.ParseBool(fs.Lookup("hsts").Value.String())
+	config.url = fmt.Sprintf("http://localhost:%s", fs.Lookup("port").Value.String())
+
 	return config
 }
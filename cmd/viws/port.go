@@ -5,21 +5,39 @@ import (
 
 	"github.com/ViBiOh/httputils/v4/pkg/httputils"
 	"github.com/ViBiOh/httputils/v4/pkg/model"
-	"github.com/klauspost/compress/gzhttp"
+	"github.com/ViBiOh/viws/pkg/dev"
 )
 
-func newPort(config configuration, clients clients, services services) http.Handler {
+func newPort(clients clients, services services) http.Handler {
 	mux := http.NewServeMux()
 
-	mux.Handle("GET /env", model.ChainMiddlewares(services.env.Handler(), services.owasp.Middleware, services.cors.Middleware))
-	mux.Handle("GET /", model.ChainMiddlewares(services.viws.Handler(), services.owasp.Middleware, services.cors.Middleware))
+	for _, envService := range services.envs {
+		if len(envService.Path()) != 0 {
+			mux.Handle("GET "+envService.Path(), model.ChainMiddlewares(envService.Handler(), services.owasp.Middleware, services.cors.Middleware))
+		}
+	}
+
+	if len(services.metrics.Path()) != 0 && !services.metrics.Separate() {
+		mux.Handle("GET "+services.metrics.Path(), services.metrics.Handler())
+	}
 
-	middlewares := []model.Middleware{clients.telemetry.Middleware("http")}
-	if *config.gzip {
-		middlewares = append(middlewares, func(next http.Handler) http.Handler {
-			return gzhttp.GzipHandler(next)
-		})
+	if services.dev != nil {
+		mux.Handle("GET "+dev.EventsPath, services.dev.Handler())
 	}
 
-	return httputils.Handler(mux, clients.health, middlewares...)
+	// Every method is routed to files, which answer OPTIONS and 405 with the allowed methods
+	mux.Handle("/", model.ChainMiddlewares(services.viws.Handler(), services.owasp.Middleware, services.cors.Middleware))
+
+	return services.accessLog.Middleware(services.releases.VersionMiddleware(httputils.Handler(services.ipFilter.Middleware(services.rateLimit.Middleware(services.proxy.Middleware(mux))), clients.health, clients.telemetry.Middleware("http"), services.compress.Middleware)))
+}
+
+func newAdminPort(clients clients, services services) http.Handler {
+	return model.ChainMiddlewares(services.admin.Handler(), clients.telemetry.Middleware("admin"))
+}
+
+func newMetricsPort(services services) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+services.metrics.Path(), services.metrics.Handler())
+
+	return mux
 }
@@ -7,7 +7,9 @@ toolchain go1.26.0
 require (
 	github.com/ViBiOh/flags v1.6.1
 	github.com/ViBiOh/httputils/v4 v4.86.3
+	github.com/andybalholm/brotli v1.2.0
 	github.com/klauspost/compress v1.18.6
+	golang.org/x/image v0.25.0
 )
 
 require (
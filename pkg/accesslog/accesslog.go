@@ -0,0 +1,302 @@
+package accesslog
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ViBiOh/flags"
+	"github.com/ViBiOh/viws/pkg/clientip"
+	"github.com/ViBiOh/viws/pkg/recorder"
+)
+
+const (
+	FormatCommon   = "common"
+	FormatCombined = "combined"
+	FormatJSON     = "json"
+
+	timeLayout = "02/Jan/2006:15:04:05 -0700"
+)
+
+type Config struct {
+	Format  string
+	File    string
+	Exclude []string
+	Sample  float64
+}
+
+func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
+	var config Config
+
+	flags.New("AccessLog", "Access log format, 'common', 'combined' or 'json', empty to disable").Prefix(prefix).DocPrefix("accessLog").StringVar(fs, &config.Format, "", overrides)
+	flags.New("AccessLogFile", "Access log file, reopened on SIGHUP, empty for standard output").Prefix(prefix).DocPrefix("accessLog").StringVar(fs, &config.File, "", overrides)
+	flags.New("AccessLogSample", "Ratio of successful requests logged, between 0 and 1, errors being always logged").Prefix(prefix).DocPrefix("accessLog").Float64Var(fs, &config.Sample, 1, overrides)
+	flags.New("AccessLogExclude", "Paths excluded from access log").Prefix(prefix).DocPrefix("accessLog").StringSliceVar(fs, &config.Exclude, []string{"/health", "/ready"}, overrides)
+
+	return &config
+}
+
+type Service struct {
+	output   *reopener
+	logger   *slog.Logger
+	format   string
+	exclude  []string
+	resolver clientip.Resolver
+	sample   float64
+}
+
+// New creates the access log, the client address being the one found by the resolver, so that it isn't a trusted proxy.
+func New(config *Config, resolver clientip.Resolver) (*Service, error) {
+	switch config.Format {
+	case "":
+		return nil, nil
+
+	case FormatCommon, FormatCombined, FormatJSON:
+
+	default:
+		return nil, fmt.Errorf("unknown format `%s`", config.Format)
+	}
+
+	if config.Sample < 0 || config.Sample > 1 {
+		return nil, fmt.Errorf("invalid sample `%g`, expecting a ratio between 0 and 1", config.Sample)
+	}
+
+	service := &Service{
+		format:   config.Format,
+		exclude:  config.Exclude,
+		sample:   config.Sample,
+		resolver: resolver,
+	}
+
+	var output io.Writer = os.Stdout
+
+	if len(config.File) != 0 {
+		file, err := newReopener(config.File)
+		if err != nil {
+			return nil, err
+		}
+
+		service.output = file
+		output = file
+	}
+
+	// Access logs have their own handler, so that they are neither filtered by the log level nor mixed with logs
+	if config.Format == FormatJSON {
+		service.logger = slog.New(slog.NewJSONHandler(output, nil))
+	} else {
+		service.logger = slog.New(lineHandler{output: output})
+	}
+
+	return service, nil
+}
+
+// Start reopens the log file on SIGHUP, after a rotation, until context is done.
+func (s *Service) Start(ctx context.Context) {
+	if s == nil || s.output == nil {
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-signals:
+			if err := s.output.reopen(); err != nil {
+				slog.LogAttrs(ctx, slog.LevelError, "reopen access log", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+func (s *Service) Close() error {
+	if s == nil || s.output == nil {
+		return nil
+	}
+
+	return s.output.Close()
+}
+
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(s.exclude, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		requestEntry := &entry{}
+		writer := recorder.New(w)
+
+		next.ServeHTTP(writer, r.WithContext(context.WithValue(r.Context(), entryKey{}, requestEntry)))
+
+		status := writer.Status()
+		if status < http.StatusBadRequest && s.sample < 1 && rand.Float64() >= s.sample {
+			return
+		}
+
+		s.log(r, start, time.Since(start), status, writer.Bytes(), requestEntry.file)
+	})
+}
+
+func (s *Service) log(r *http.Request, start time.Time, duration time.Duration, status int, bytes int64, file string) {
+	if s.format == FormatJSON {
+		s.logger.LogAttrs(r.Context(), slog.LevelInfo, "access",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("query", r.URL.RawQuery),
+			slog.String("proto", r.Proto),
+			slog.Int("status", status),
+			slog.Int64("bytes", bytes),
+			slog.Duration("duration", duration),
+			slog.String("remote", s.clientIP(r)),
+			slog.String("referer", r.Referer()),
+			slog.String("user_agent", r.UserAgent()),
+			slog.String("file", file),
+		)
+
+		return
+	}
+
+	var builder strings.Builder
+
+	username := "-"
+	if user, _, ok := r.BasicAuth(); ok && len(user) != 0 {
+		username = user
+	}
+
+	size := "-"
+	if bytes > 0 {
+		size = strconv.FormatInt(bytes, 10)
+	}
+
+	_, _ = fmt.Fprintf(&builder, `%s - %s [%s] "%s %s %s" %d %s`, s.clientIP(r), username, start.Format(timeLayout), r.Method, r.URL.RequestURI(), r.Proto, status, size)
+
+	if s.format == FormatCombined {
+		_, _ = fmt.Fprintf(&builder, ` %s %s`, quote(r.Referer()), quote(r.UserAgent()))
+	}
+
+	s.logger.LogAttrs(r.Context(), slog.LevelInfo, builder.String())
+}
+
+func (s *Service) clientIP(r *http.Request) string {
+	if ip := s.resolver.IP(r); ip != nil {
+		return ip.String()
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
+
+func quote(value string) string {
+	if len(value) == 0 {
+		return `"-"`
+	}
+
+	return strconv.Quote(value)
+}
+
+type entryKey struct{}
+
+type entry struct {
+	file string
+}
+
+// SetFile records the file resolved for the request, when access logs are enabled.
+func SetFile(ctx context.Context, file string) {
+	if current, ok := ctx.Value(entryKey{}).(*entry); ok {
+		current.file = file
+	}
+}
+
+// lineHandler writes the message of records as is, one per line, for the Common and Combined Log Format.
+type lineHandler struct {
+	output io.Writer
+}
+
+func (lineHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h lineHandler) WithAttrs([]slog.Attr) slog.Handler     { return h }
+func (h lineHandler) WithGroup(string) slog.Handler          { return h }
+
+func (h lineHandler) Handle(_ context.Context, record slog.Record) error {
+	_, err := io.WriteString(h.output, record.Message+"\n")
+	return err
+}
+
+// reopener is a file that can be closed and opened again at the same path, after a rotation.
+type reopener struct {
+	file  *os.File
+	name  string
+	mutex sync.Mutex
+}
+
+func newReopener(name string) (*reopener, error) {
+	file, err := openLog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reopener{file: file, name: name}, nil
+}
+
+func openLog(name string) (*os.File, error) {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open `%s`: %w", name, err)
+	}
+
+	return file, nil
+}
+
+func (r *reopener) Write(content []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.file.Write(content)
+}
+
+func (r *reopener) reopen() error {
+	file, err := openLog(r.name)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	previous := r.file
+	r.file = file
+
+	return previous.Close()
+}
+
+func (r *reopener) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.file.Close()
+}
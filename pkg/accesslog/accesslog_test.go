@@ -0,0 +1,218 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/viws/pkg/clientip"
+)
+
+func TestFlags(t *testing.T) {
+	cases := map[string]struct {
+		want string
+	}{
+		"simple": {
+			"Usage of simple:\n  -accessLog string\n    \t[accessLog] Access log format, 'common', 'combined' or 'json', empty to disable ${SIMPLE_ACCESS_LOG}\n  -accessLogExclude string slice\n    \t[accessLog] Paths excluded from access log ${SIMPLE_ACCESS_LOG_EXCLUDE}, as a string slice, environment variable separated by \",\" (default [/health, /ready])\n  -accessLogFile string\n    \t[accessLog] Access log file, reopened on SIGHUP, empty for standard output ${SIMPLE_ACCESS_LOG_FILE}\n  -accessLogSample float\n    \t[accessLog] Ratio of successful requests logged, between 0 and 1, errors being always logged ${SIMPLE_ACCESS_LOG_SAMPLE} (default 1)\n",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			fs := flag.NewFlagSet(intention, flag.ContinueOnError)
+			Flags(fs, "")
+
+			var writer strings.Builder
+			fs.SetOutput(&writer)
+			fs.Usage()
+
+			result := writer.String()
+
+			if result != tc.want {
+				t.Errorf("Flags() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	if service, err := New(&Config{}, clientip.Resolver{}); service != nil || err != nil {
+		t.Errorf("New() = (%v, %s), want (nil, nil)", service, err)
+	}
+
+	if _, err := New(&Config{Format: "apache"}, clientip.Resolver{}); err == nil {
+		t.Error("New() = nil, want error")
+	}
+
+	for _, sample := range []float64{-0.1, 1.5} {
+		if _, err := New(&Config{Format: FormatCommon, Sample: sample}, clientip.Resolver{}); err == nil {
+			t.Errorf("New() with sample %g = nil, want error", sample)
+		}
+	}
+
+	service, err := New(&Config{Format: FormatJSON, Sample: 1}, clientip.Resolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := service.logger.Handler().(*slog.JSONHandler); !ok || service.logger == slog.Default() {
+		t.Errorf("New() logger = %T, want a dedicated JSON handler", service.logger.Handler())
+	}
+}
+
+func serve(t *testing.T, config Config, request *http.Request) string {
+	t.Helper()
+
+	config.File = filepath.Join(t.TempDir(), "access.log")
+
+	resolver, err := clientip.New(&clientip.Config{TrustedProxies: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := New(&config, resolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetFile(r.Context(), "/www/index.html")
+
+		if r.URL.Path == "/nowhere" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte("hello"))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	if err := service.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(config.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestMiddleware(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/index.html?lang=fr", nil)
+	request.RemoteAddr = "192.0.2.1:4321"
+	request.Header.Set("Referer", "https://example.com/")
+	request.Header.Set("User-Agent", "curl/8.0")
+	request.SetBasicAuth("admin", "secret")
+
+	proxied := httptest.NewRequest(http.MethodGet, "/index.html", nil)
+	proxied.RemoteAddr = "10.0.0.1:4321"
+	proxied.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	cases := map[string]struct {
+		config  Config
+		request *http.Request
+		want    *regexp.Regexp
+	}{
+		"common": {
+			Config{Format: FormatCommon, Sample: 1},
+			request,
+			regexp.MustCompile(`^192\.0\.2\.1 - admin \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /index\.html\?lang=fr HTTP/1\.1" 200 5\n$`),
+		},
+		"combined": {
+			Config{Format: FormatCombined, Sample: 1},
+			request,
+			regexp.MustCompile(`" 200 5 "https://example\.com/" "curl/8\.0"\n$`),
+		},
+		"behind trusted proxy": {
+			Config{Format: FormatCommon, Sample: 1},
+			proxied,
+			regexp.MustCompile(`^203\.0\.113\.7 - - `),
+		},
+		"excluded": {
+			Config{Format: FormatCombined, Sample: 1, Exclude: []string{"/health"}},
+			httptest.NewRequest(http.MethodGet, "/health", nil),
+			regexp.MustCompile(`^$`),
+		},
+		"sampled out": {
+			Config{Format: FormatCombined, Sample: 0},
+			request,
+			regexp.MustCompile(`^$`),
+		},
+		"error always logged": {
+			Config{Format: FormatCombined, Sample: 0},
+			httptest.NewRequest(http.MethodGet, "/nowhere", nil),
+			regexp.MustCompile(`"GET /nowhere HTTP/1\.1" 404 \d+ "-" "-"\n$`),
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if result := serve(t, tc.config, tc.request); !tc.want.MatchString(result) {
+				t.Errorf("Middleware() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareJSON(t *testing.T) {
+	content := serve(t, Config{Format: FormatJSON, Sample: 1}, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(content), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]any{
+		"method": "GET",
+		"path":   "/",
+		"status": float64(http.StatusOK),
+		"bytes":  float64(5),
+		"file":   "/www/index.html",
+	} {
+		if result := record[key]; result != want {
+			t.Errorf("%s = %v, want %v", key, result, want)
+		}
+	}
+}
+
+func TestReopen(t *testing.T) {
+	directory := t.TempDir()
+	filename := filepath.Join(directory, "access.log")
+
+	output, err := newReopener(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = output.Write([]byte("before\n"))
+
+	if err := os.Rename(filename, filename+".1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := output.reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = output.Write([]byte("after\n"))
+
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{filename + ".1": "before\n", filename: "after\n"} {
+		if content, _ := os.ReadFile(name); string(content) != want {
+			t.Errorf("`%s` = `%s`, want `%s`", name, content, want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/ViBiOh/flags"
+	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+	"github.com/ViBiOh/httputils/v4/pkg/httpjson"
+	"github.com/ViBiOh/viws/pkg/release"
+)
+
+const bearerPrefix = "Bearer "
+
+var errUnauthorized = errors.New("invalid or missing bearer token")
+
+type Config struct {
+	Token   string
+	MaxSize int64
+}
+
+func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
+	var config Config
+
+	flags.New("Token", "Bearer token of the admin API, empty to disable").Prefix(prefix).DocPrefix("admin").StringVar(fs, &config.Token, "", overrides)
+	flags.New("MaxSize", "Maximum size of an uploaded bundle, in bytes, before and after decompression").Prefix(prefix).DocPrefix("admin").Int64Var(fs, &config.MaxSize, 100<<20, overrides)
+
+	return &config
+}
+
+type Service struct {
+	releases *release.Service
+	token    []byte
+	maxSize  int64
+}
+
+func New(config *Config, releases *release.Service) (*Service, error) {
+	if len(config.Token) == 0 {
+		return nil, nil
+	}
+
+	if releases == nil {
+		return nil, errors.New("admin API requires releases to be configured")
+	}
+
+	return &Service{
+		releases: releases,
+		token:    []byte(config.Token),
+		maxSize:  config.MaxSize,
+	}, nil
+}
+
+func (s *Service) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /releases", s.list)
+	mux.HandleFunc("PUT /releases/{id}", s.upload)
+	mux.HandleFunc("POST /releases/{id}/activate", s.activate)
+	mux.HandleFunc("DELETE /releases/{id}", s.delete)
+	mux.HandleFunc("POST /rollback", s.rollback)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httperror.Unauthorized(r.Context(), w, errUnauthorized)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Service) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), bearerPrefix)
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), s.token) == 1
+}
+
+func (s *Service) list(w http.ResponseWriter, r *http.Request) {
+	releases, err := s.releases.List()
+	if err != nil {
+		httperror.InternalServerError(r.Context(), w, err)
+		return
+	}
+
+	httpjson.Write(r.Context(), w, http.StatusOK, releases)
+}
+
+// upload unpacks a tar or tar.gz bundle as a new release, activated unless `activate=false` is given.
+func (s *Service) upload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	body := http.MaxBytesReader(w, r.Body, s.maxSize)
+
+	err := s.releases.Create(id, func(directory string) error {
+		return extract(body, directory, s.maxSize)
+	})
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			err = errors.Join(ErrTooLarge, err)
+		}
+
+		s.handleError(w, r, err)
+		return
+	}
+
+	if r.URL.Query().Get("activate") != "false" {
+		if err := s.releases.Activate(id); err != nil {
+			s.handleError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Service) activate(w http.ResponseWriter, r *http.Request) {
+	if err := s.releases.Activate(r.PathValue("id")); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) delete(w http.ResponseWriter, r *http.Request) {
+	if err := s.releases.Delete(r.PathValue("id")); err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) rollback(w http.ResponseWriter, r *http.Request) {
+	previous, err := s.releases.Rollback()
+	if err != nil {
+		s.handleError(w, r, err)
+		return
+	}
+
+	httpjson.Write(r.Context(), w, http.StatusOK, previous)
+}
+
+func (s *Service) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrTooLarge):
+		httperror.BadRequest(r.Context(), w, fmt.Errorf("%w, limit is %d bytes", ErrTooLarge, s.maxSize))
+
+	case errors.Is(err, release.ErrInvalidID), errors.Is(err, ErrInvalidBundle):
+		httperror.BadRequest(r.Context(), w, err)
+
+	case errors.Is(err, release.ErrNotFound):
+		httperror.NotFound(r.Context(), w, err)
+
+	case errors.Is(err, release.ErrExists):
+		// httperror has no helper for 409, headers mirror its own so that the response is never cached
+		w.Header().Add("Cache-Control", "no-cache")
+		http.Error(w, err.Error(), http.StatusConflict)
+
+	case errors.Is(err, release.ErrActive), errors.Is(err, release.ErrNoPrevious):
+		httperror.BadRequest(r.Context(), w, err)
+
+	default:
+		slog.LogAttrs(r.Context(), slog.LevelError, "admin", slog.String("path", r.URL.Path), slog.Any("error", err))
+		httperror.InternalServerError(r.Context(), w, err)
+	}
+}
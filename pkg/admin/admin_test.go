@@ -0,0 +1,303 @@
+package admin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/viws/pkg/release"
+)
+
+func TestFlags(t *testing.T) {
+	cases := map[string]struct {
+		want string
+	}{
+		"simple": {
+			"Usage of simple:\n  -maxSize int\n    \t[admin] Maximum size of an uploaded bundle, in bytes, before and after decompression ${SIMPLE_MAX_SIZE} (default 104857600)\n  -token string\n    \t[admin] Bearer token of the admin API, empty to disable ${SIMPLE_TOKEN}\n",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			fs := flag.NewFlagSet(intention, flag.ContinueOnError)
+			Flags(fs, "")
+
+			var writer strings.Builder
+			fs.SetOutput(&writer)
+			fs.Usage()
+
+			result := writer.String()
+
+			if result != tc.want {
+				t.Errorf("Flags() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func bundle(t *testing.T, compress bool, files ...string) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	var output *tar.Writer
+	var gzipWriter *gzip.Writer
+
+	if compress {
+		gzipWriter = gzip.NewWriter(&buffer)
+		output = tar.NewWriter(gzipWriter)
+	} else {
+		output = tar.NewWriter(&buffer)
+	}
+
+	for _, name := range files {
+		if err := output.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(name))}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := output.Write([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := output.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if gzipWriter != nil {
+		if err := gzipWriter.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return buffer.Bytes()
+}
+
+// newHandler returns the root of releases and a caller of the admin API, with the given releases already uploaded.
+func newHandler(t *testing.T, existing ...string) (string, func(method, path, token string, body []byte) *httptest.ResponseRecorder) {
+	t.Helper()
+
+	root := t.TempDir()
+
+	releases, err := release.New(&release.Config{Directory: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := New(&Config{Token: "secret", MaxSize: 8192}, releases)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := service.Handler()
+
+	call := func(method, path, token string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		if len(token) != 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		writer := httptest.NewRecorder()
+		handler.ServeHTTP(writer, req)
+
+		return writer
+	}
+
+	for _, id := range existing {
+		if result := call(http.MethodPut, "/releases/"+id, "secret", bundle(t, false, "index.html")); result.Code != http.StatusCreated {
+			t.Fatalf("create `%s` = %d `%s`", id, result.Code, result.Body.String())
+		}
+	}
+
+	return root, call
+}
+
+func TestHandler(t *testing.T) {
+	cases := map[string]struct {
+		existing   []string
+		method     string
+		path       string
+		token      string
+		body       []byte
+		wantStatus int
+	}{
+		"missing token": {
+			nil,
+			http.MethodGet,
+			"/releases",
+			"",
+			nil,
+			http.StatusUnauthorized,
+		},
+		"invalid token": {
+			nil,
+			http.MethodGet,
+			"/releases",
+			"guess",
+			nil,
+			http.StatusUnauthorized,
+		},
+		"create": {
+			nil,
+			http.MethodPut,
+			"/releases/v1",
+			"secret",
+			bundle(t, true, "index.html", "assets/index.js"),
+			http.StatusCreated,
+		},
+		"existing release": {
+			[]string{"v1"},
+			http.MethodPut,
+			"/releases/v1",
+			"secret",
+			bundle(t, true, "index.html"),
+			http.StatusConflict,
+		},
+		"without index": {
+			nil,
+			http.MethodPut,
+			"/releases/v1",
+			"secret",
+			bundle(t, false, "about.html"),
+			http.StatusBadRequest,
+		},
+		"path traversal": {
+			nil,
+			http.MethodPut,
+			"/releases/v1",
+			"secret",
+			bundle(t, false, "index.html", "../escape.html"),
+			http.StatusBadRequest,
+		},
+		"too large": {
+			nil,
+			http.MethodPut,
+			"/releases/v1",
+			"secret",
+			bundle(t, false, "index.html", strings.Repeat("a", 10000)),
+			http.StatusBadRequest,
+		},
+		"duplicate entry": {
+			nil,
+			http.MethodPut,
+			"/releases/v1",
+			"secret",
+			bundle(t, false, "index.html", "index.html"),
+			http.StatusBadRequest,
+		},
+		"hidden id": {
+			nil,
+			http.MethodPut,
+			"/releases/.hidden",
+			"secret",
+			bundle(t, false, "index.html"),
+			http.StatusBadRequest,
+		},
+		"delete active": {
+			[]string{"v1", "v2"},
+			http.MethodDelete,
+			"/releases/v2",
+			"secret",
+			nil,
+			http.StatusBadRequest,
+		},
+		"delete": {
+			[]string{"v1", "v2"},
+			http.MethodDelete,
+			"/releases/v1",
+			"secret",
+			nil,
+			http.StatusNoContent,
+		},
+		"rollback": {
+			[]string{"v1", "v2"},
+			http.MethodPost,
+			"/rollback",
+			"secret",
+			nil,
+			http.StatusOK,
+		},
+		"rollback without previous": {
+			[]string{"v1"},
+			http.MethodPost,
+			"/rollback",
+			"secret",
+			nil,
+			http.StatusBadRequest,
+		},
+		"activate": {
+			[]string{"v1", "v2"},
+			http.MethodPost,
+			"/releases/v1/activate",
+			"secret",
+			nil,
+			http.StatusNoContent,
+		},
+		"activate unknown": {
+			[]string{"v1"},
+			http.MethodPost,
+			"/releases/v9/activate",
+			"secret",
+			nil,
+			http.StatusNotFound,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			_, call := newHandler(t, tc.existing...)
+
+			if result := call(tc.method, tc.path, tc.token, tc.body); result.Code != tc.wantStatus {
+				t.Errorf("%s %s = %d `%s`, want %d", tc.method, tc.path, result.Code, result.Body.String(), tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHandlerStaging(t *testing.T) {
+	root, call := newHandler(t, "v1")
+
+	for _, body := range [][]byte{
+		bundle(t, false, "about.html"),
+		bundle(t, false, "index.html", strings.Repeat("a", 10000)),
+		bundle(t, false, "index.html", "index.html"),
+	} {
+		if result := call(http.MethodPut, "/releases/v2", "secret", body); result.Code != http.StatusBadRequest {
+			t.Errorf("PUT /releases/v2 = %d `%s`, want %d", result.Code, result.Body.String(), http.StatusBadRequest)
+		}
+	}
+
+	if content, _ := os.ReadFile(filepath.Join(root, "releases", "v1", "index.html")); string(content) != "index.html" {
+		t.Errorf("index.html = `%s`, want `index.html`", content)
+	}
+
+	var list []release.Release
+	if err := json.NewDecoder(call(http.MethodGet, "/releases", "secret", nil).Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].ID != "v1" || !list[0].Active {
+		t.Errorf("list = %+v, want only active v1", list)
+	}
+
+	if entries, _ := os.ReadDir(filepath.Join(root, "releases")); len(entries) != 1 {
+		t.Errorf("releases directory has %d entries, want no staging left", len(entries))
+	}
+}
+
+func TestNew(t *testing.T) {
+	if service, err := New(&Config{}, nil); service != nil || err != nil {
+		t.Errorf("New() = (%v, %s), want (nil, nil)", service, err)
+	}
+
+	if _, err := New(&Config{Token: "secret"}, nil); err == nil {
+		t.Error("New() = nil, want error without releases")
+	}
+}
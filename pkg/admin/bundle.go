@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const indexFilename = "index.html"
+
+var (
+	ErrInvalidBundle = errors.New("invalid bundle")
+	ErrTooLarge      = errors.New("bundle too large")
+	gzipMagic        = []byte{0x1f, 0x8b}
+)
+
+// extract unpacks a tar or tar.gz bundle in the given directory. It rejects entries escaping the directory, duplicated
+// files, links and special files, and stops as soon as the uncompressed content exceeds the given size.
+func extract(reader io.Reader, directory string, maxSize int64) error {
+	buffered := bufio.NewReader(reader)
+
+	if magic, err := buffered.Peek(len(gzipMagic)); err == nil && bytes.Equal(magic, gzipMagic) {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return fmt.Errorf("%w: gzip: %w", ErrInvalidBundle, err)
+		}
+
+		defer func() { _ = gzipReader.Close() }()
+
+		reader = gzipReader
+	} else {
+		reader = buffered
+	}
+
+	tarReader := tar.NewReader(reader)
+	remaining := maxSize
+	hasIndex := false
+	files := make(map[string]bool)
+
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: tar: %w", ErrInvalidBundle, err)
+		}
+
+		name, err := entryName(header.Name)
+		if err != nil {
+			return err
+		}
+
+		if len(name) == 0 {
+			continue
+		}
+
+		target := filepath.Join(directory, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if files[name] {
+				return fmt.Errorf("%w: `%s` is both a file and a directory", ErrInvalidBundle, header.Name)
+			}
+
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return fmt.Errorf("create directory `%s`: %w", name, err)
+			}
+
+		case tar.TypeReg:
+			if files[name] {
+				return fmt.Errorf("%w: duplicate entry `%s`", ErrInvalidBundle, header.Name)
+			}
+
+			files[name] = true
+
+			if header.Size > remaining {
+				return ErrTooLarge
+			}
+
+			remaining -= header.Size
+
+			if err := writeEntry(tarReader, target, header.Size); err != nil {
+				return fmt.Errorf("write `%s`: %w", name, err)
+			}
+
+			if name == indexFilename {
+				hasIndex = true
+			}
+
+		default:
+			return fmt.Errorf("%w: `%s` is not a regular file nor a directory", ErrInvalidBundle, header.Name)
+		}
+	}
+
+	if !hasIndex {
+		return fmt.Errorf("%w: no `%s` at root", ErrInvalidBundle, indexFilename)
+	}
+
+	return nil
+}
+
+func entryName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+
+	if path.IsAbs(name) || strings.Contains(name, ":") {
+		return "", fmt.Errorf("%w: absolute path `%s`", ErrInvalidBundle, name)
+	}
+
+	for part := range strings.SplitSeq(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%w: path traversal `%s`", ErrInvalidBundle, name)
+		}
+	}
+
+	if name = path.Clean(name); name == "." {
+		return "", nil
+	}
+
+	return name, nil
+}
+
+func writeEntry(reader io.Reader, target string, size int64) (err error) {
+	if err = os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	_, err = io.CopyN(file, reader, size)
+
+	return err
+}
@@ -0,0 +1,50 @@
+package admin
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEntryName(t *testing.T) {
+	cases := map[string]struct {
+		input   string
+		want    string
+		wantErr error
+	}{
+		"simple": {
+			"./assets/index.js",
+			"assets/index.js",
+			nil,
+		},
+		"root": {
+			"./",
+			"",
+			nil,
+		},
+		"absolute": {
+			"/etc/passwd",
+			"",
+			ErrInvalidBundle,
+		},
+		"windows absolute": {
+			"C:\\Windows",
+			"",
+			ErrInvalidBundle,
+		},
+		"traversal": {
+			"assets/../../index.html",
+			"",
+			ErrInvalidBundle,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			result, err := entryName(tc.input)
+
+			if result != tc.want || !errors.Is(err, tc.wantErr) {
+				t.Errorf("entryName() = (`%s`, `%s`), want (`%s`, `%s`)", result, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"github.com/ViBiOh/viws/pkg/metrics"
+)
+
+type entry struct {
+	key     string
+	content []byte
+}
+
+type call struct {
+	done    chan struct{}
+	content []byte
+	err     error
+}
+
+// Cache holds computed contents, the least recently used being evicted first when over its memory budget.
+// Concurrent requests of a missing content wait for a single computation.
+type Cache struct {
+	entries  map[string]*list.Element
+	calls    map[string]*call
+	lru      *list.List
+	hits     *metrics.Counter
+	misses   *metrics.Counter
+	waits    *metrics.Counter
+	memory   *metrics.Gauge
+	maxBytes int
+	bytes    int
+	mutex    sync.Mutex
+}
+
+// New returns nil when maxBytes is zero, contents being then computed on each call. Metrics are named after the
+// cache, e.g. viws_<name>_cache_hits_total, viws_<name>_cache_misses_total and viws_<name>_cache_waits_total.
+func New(maxBytes uint, registry *metrics.Registry, name string) *Cache {
+	if maxBytes == 0 {
+		return nil
+	}
+
+	return &Cache{
+		entries:  make(map[string]*list.Element),
+		calls:    make(map[string]*call),
+		lru:      list.New(),
+		maxBytes: int(maxBytes),
+		hits:     registry.Counter(fmt.Sprintf("viws_%s_cache_hits_total", name), fmt.Sprintf("Contents served from %s cache", name)),
+		misses:   registry.Counter(fmt.Sprintf("viws_%s_cache_misses_total", name), fmt.Sprintf("Contents computed on request by %s cache", name)),
+		waits:    registry.Counter(fmt.Sprintf("viws_%s_cache_waits_total", name), fmt.Sprintf("Requests waiting for a content being computed by %s cache", name)),
+		memory:   registry.Gauge(fmt.Sprintf("viws_%s_cache_bytes", name), fmt.Sprintf("Bytes of contents in %s cache", name)),
+	}
+}
+
+func (c *Cache) Get(key string, compute func() ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return compute()
+	}
+
+	c.mutex.Lock()
+
+	if element, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(element)
+		c.mutex.Unlock()
+
+		c.hits.Inc()
+
+		return element.Value.(*entry).content, nil
+	}
+
+	if pending, ok := c.calls[key]; ok {
+		c.mutex.Unlock()
+
+		// Neither a hit nor a miss: the content wasn't in cache, but isn't computed again
+		c.waits.Inc()
+		<-pending.done
+
+		return pending.content, pending.err
+	}
+
+	pending := &call{done: make(chan struct{})}
+	c.calls[key] = pending
+	c.mutex.Unlock()
+
+	c.misses.Inc()
+
+	pending.content, pending.err = compute()
+
+	c.mutex.Lock()
+	delete(c.calls, key)
+
+	if pending.err == nil {
+		c.add(key, pending.content)
+	}
+
+	c.mutex.Unlock()
+	close(pending.done)
+
+	return pending.content, pending.err
+}
+
+// add stores the content, lock being held. Content larger than the whole budget is never stored.
+func (c *Cache) add(key string, content []byte) {
+	if len(content) > c.maxBytes {
+		return
+	}
+
+	for c.bytes+len(content) > c.maxBytes {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+
+		evicted := oldest.Value.(*entry)
+		delete(c.entries, evicted.key)
+		c.bytes -= len(evicted.content)
+	}
+
+	c.entries[key] = c.lru.PushFront(&entry{key: key, content: content})
+	c.bytes += len(content)
+	c.memory.Set(int64(c.bytes))
+}
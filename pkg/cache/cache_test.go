@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/viws/pkg/metrics"
+)
+
+func TestGet(t *testing.T) {
+	instance := New(10, nil, "test")
+
+	var computes int
+	compute := func(size int) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			computes++
+			return make([]byte, size), nil
+		}
+	}
+
+	for _, key := range []string{"a", "b", "a", "c"} {
+		if _, err := instance.Get(key, compute(4)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if computes != 3 {
+		t.Errorf("get() computed %d times, want 3", computes)
+	}
+
+	if _, ok := instance.entries["b"]; ok {
+		t.Error("get() kept least recently used entry")
+	}
+
+	if instance.bytes != 8 {
+		t.Errorf("get() bytes = %d, want 8", instance.bytes)
+	}
+
+	if _, err := instance.Get("large", compute(11)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := instance.entries["large"]; ok || instance.bytes != 8 {
+		t.Error("get() stored content larger than budget")
+	}
+}
+
+func TestGetWaits(t *testing.T) {
+	registry := metrics.New(&metrics.Config{Path: "/metrics"})
+	instance := New(10, registry, "test")
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_, _ = instance.Get("a", func() ([]byte, error) {
+			<-release
+			return []byte("a"), nil
+		})
+	}()
+
+	for instance.misses.Value() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	waiting := make(chan []byte)
+	go func() {
+		content, _ := instance.Get("a", func() ([]byte, error) {
+			t.Error("Get() computed content in progress")
+			return nil, nil
+		})
+
+		waiting <- content
+	}()
+
+	for instance.waits.Value() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	<-done
+
+	if content := <-waiting; string(content) != "a" {
+		t.Errorf("Get() = `%s`, want `a`", content)
+	}
+
+	if _, err := instance.Get("a", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if hits, misses, waits := instance.hits.Value(), instance.misses.Value(), instance.waits.Value(); hits != 1 || misses != 1 || waits != 1 {
+		t.Errorf("Get() counted %d hits, %d misses and %d waits, want 1 of each", hits, misses, waits)
+	}
+}
@@ -0,0 +1,116 @@
+package clientip
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ViBiOh/flags"
+)
+
+const forwardedForHeader = "X-Forwarded-For"
+
+type Config struct {
+	TrustedProxies []string
+}
+
+func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
+	var config Config
+
+	flags.New("TrustedProxies", "IP or CIDR of proxies trusted to set X-Forwarded-For").Prefix(prefix).DocPrefix("clientIP").StringSliceVar(fs, &config.TrustedProxies, nil, overrides)
+
+	return &config
+}
+
+// Resolver finds the IP of the client, X-Forwarded-For being only read when the request comes from a trusted proxy.
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+func New(config *Config) (Resolver, error) {
+	var resolver Resolver
+
+	for _, value := range config.TrustedProxies {
+		network, err := ParseNetwork(value)
+		if err != nil {
+			return resolver, fmt.Errorf("trusted proxy: %w", err)
+		}
+
+		resolver.trusted = append(resolver.trusted, network)
+	}
+
+	return resolver, nil
+}
+
+// ParseNetwork parses a CIDR, or a single IP as a network containing only itself.
+func ParseNetwork(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse `%s`: %w", value, err)
+		}
+
+		return network, nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("parse `%s`: invalid IP", value)
+	}
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return &net.IPNet{IP: ipv4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// IP returns the client IP. Addresses of X-Forwarded-For are read from the right, the last one that is not a trusted
+// proxy being the client: entries on its left could have been forged by the client itself.
+func (r Resolver) IP(req *http.Request) net.IP {
+	ip := parseIP(req.RemoteAddr)
+	if ip == nil || !r.isTrusted(ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(req.Header.Values(forwardedForHeader), ","), ",")
+
+	for index := len(forwarded) - 1; index >= 0; index-- {
+		candidate := parseIP(forwarded[index])
+		if candidate == nil {
+			break
+		}
+
+		ip = candidate
+
+		if !r.isTrusted(candidate) {
+			break
+		}
+	}
+
+	return ip
+}
+
+func (r Resolver) isTrusted(ip net.IP) bool {
+	for _, network := range r.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseIP(value string) net.IP {
+	value = strings.TrimSpace(value)
+
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+
+	return net.ParseIP(strings.Trim(value, "[]"))
+}
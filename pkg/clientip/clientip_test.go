@@ -0,0 +1,128 @@
+package clientip
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlags(t *testing.T) {
+	cases := map[string]struct {
+		want string
+	}{
+		"simple": {
+			"Usage of simple:\n  -trustedProxies string slice\n    \t[clientIP] IP or CIDR of proxies trusted to set X-Forwarded-For ${SIMPLE_TRUSTED_PROXIES}, as a string slice, environment variable separated by \",\"\n",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			fs := flag.NewFlagSet(intention, flag.ContinueOnError)
+			Flags(fs, "")
+
+			var writer strings.Builder
+			fs.SetOutput(&writer)
+			fs.Usage()
+
+			result := writer.String()
+
+			if result != tc.want {
+				t.Errorf("Flags() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		proxies []string
+		wantErr bool
+	}{
+		"empty": {
+			nil,
+			false,
+		},
+		"ip and cidr": {
+			[]string{"10.0.0.1", "192.168.0.0/16", "fd00::/8"},
+			false,
+		},
+		"invalid": {
+			[]string{"10.0.0"},
+			true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if _, err := New(&Config{TrustedProxies: tc.proxies}); (err != nil) != tc.wantErr {
+				t.Errorf("New() = %s, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestIP(t *testing.T) {
+	resolver, err := New(&Config{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		"direct": {
+			"203.0.113.5:1234",
+			nil,
+			"203.0.113.5",
+		},
+		"forged by untrusted": {
+			"203.0.113.5:1234",
+			[]string{"198.51.100.1"},
+			"203.0.113.5",
+		},
+		"trusted proxy": {
+			"10.1.2.3:1234",
+			[]string{"198.51.100.1"},
+			"198.51.100.1",
+		},
+		"chain of proxies": {
+			"10.1.2.3:1234",
+			[]string{"1.2.3.4, 198.51.100.1, 192.0.2.10"},
+			"198.51.100.1",
+		},
+		"several headers": {
+			"10.1.2.3:1234",
+			[]string{"1.2.3.4", "198.51.100.1"},
+			"198.51.100.1",
+		},
+		"invalid entry": {
+			"10.1.2.3:1234",
+			[]string{"garbage, 10.4.5.6"},
+			"10.4.5.6",
+		},
+		"ipv6": {
+			"[2001:db8::1]:1234",
+			nil,
+			"2001:db8::1",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = tc.remote
+
+			for _, value := range tc.forwarded {
+				request.Header.Add(forwardedForHeader, value)
+			}
+
+			if result := resolver.IP(request).String(); result != tc.want {
+				t.Errorf("IP() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
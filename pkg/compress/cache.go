@@ -0,0 +1,53 @@
+package compress
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Varies checks that responses of this Content-Type depend on Accept-Encoding, being compressed for clients accepting
+// it.
+func (s *Service) Varies(contentType string) bool {
+	return s != nil && s.allowed(contentType)
+}
+
+// Cacheable checks that a file of this Content-Type and size is served from the cache of compressed variants. Files
+// larger than the whole cache are streamed, as they would be read in memory and compressed again on each request.
+func (s *Service) Cacheable(contentType string, size int64) bool {
+	return s != nil && s.cache != nil && size >= int64(s.minSize) && size <= int64(s.maxSize) && s.allowed(contentType)
+}
+
+// Compressed returns the content given by load compressed with the encoding, computed once per path, ETag and
+// encoding as long as it stays in cache.
+func (s *Service) Compressed(path, etag, encoding string, load func() ([]byte, error)) ([]byte, error) {
+	pool, ok := s.pools[encoding]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding `%s`", encoding)
+	}
+
+	return s.cache.Get(path+"|"+etag+"|"+encoding, func() ([]byte, error) {
+		content, err := load()
+		if err != nil {
+			return nil, fmt.Errorf("load: %w", err)
+		}
+
+		var output bytes.Buffer
+
+		writer := pool.Get().(encoder)
+		defer pool.Put(writer)
+
+		writer.Reset(&output)
+
+		if _, err = writer.Write(content); err == nil {
+			err = writer.Close()
+		}
+
+		writer.Reset(nil)
+
+		if err != nil {
+			return nil, fmt.Errorf("compress: %w", err)
+		}
+
+		return output.Bytes(), nil
+	})
+}
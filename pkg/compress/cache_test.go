@@ -0,0 +1,142 @@
+package compress
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ViBiOh/viws/pkg/metrics"
+)
+
+func TestCompressed(t *testing.T) {
+	config := testConfig()
+	config.CacheSize = 1 << 20
+
+	registry := metrics.New(&metrics.Config{Path: "/metrics"})
+
+	service, err := New(&config, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte(strings.Repeat("body { color: red; }\n", 100))
+
+	var loads atomic.Int32
+	load := func() ([]byte, error) {
+		loads.Add(1)
+		return content, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			compressed, err := service.Compressed("/index.css", `W/"1"`, Gzip, load)
+			if err != nil {
+				t.Errorf("Compressed() = %v", err)
+				return
+			}
+
+			if got := decode(t, Gzip, bytes.NewReader(compressed)); got != string(content) {
+				t.Errorf("Compressed() decoded = `%s`", got)
+			}
+		})
+	}
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("Compressed() loaded %d times, want 1", got)
+	}
+
+	if _, err = service.Compressed("/index.css", `W/"1"`, Brotli, load); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = service.Compressed("/index.css", `W/"2"`, Gzip, load); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := loads.Load(); got != 3 {
+		t.Errorf("Compressed() loaded %d times, want 3 with other encoding and ETag", got)
+	}
+
+	var output strings.Builder
+	registry.Write(&output)
+
+	if !strings.Contains(output.String(), "viws_compress_cache_misses_total 3") {
+		t.Errorf("Write() = `%s`, want 3 misses", output.String())
+	}
+
+	// Concurrent requests either wait for the compression or find it done, depending on scheduling
+	if served := registry.Counter("viws_compress_cache_hits_total", "").Value() + registry.Counter("viws_compress_cache_waits_total", "").Value(); served != 9 {
+		t.Errorf("Compressed() served %d from cache or in progress compression, want 9", served)
+	}
+
+	if _, err = service.Compressed("/index.css", `W/"1"`, "deflate", load); err == nil {
+		t.Error("Compressed() with unknown encoding succeeded")
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	config := testConfig()
+	config.CacheSize = 1024
+
+	service, err := New(&config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disabled := testConfig()
+
+	uncached, err := New(&disabled, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		service     *Service
+		contentType string
+		size        int64
+		want        bool
+	}{
+		"disabled": {
+			uncached,
+			"text/css",
+			512,
+			false,
+		},
+		"cacheable": {
+			service,
+			"text/css",
+			512,
+			true,
+		},
+		"too small": {
+			service,
+			"text/css",
+			10,
+			false,
+		},
+		"larger than cache": {
+			service,
+			"text/css",
+			2048,
+			false,
+		},
+		"not compressible": {
+			service,
+			"image/png",
+			512,
+			false,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := tc.service.Cacheable(tc.contentType, tc.size); got != tc.want {
+				t.Errorf("Cacheable() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
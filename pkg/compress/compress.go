@@ -0,0 +1,246 @@
+package compress
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ViBiOh/flags"
+	"github.com/ViBiOh/viws/pkg/cache"
+	"github.com/ViBiOh/viws/pkg/metrics"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	Brotli = "br"
+	Zstd   = "zstd"
+	Gzip   = "gzip"
+)
+
+var errNoEncoding = errors.New("no encoding enabled")
+
+type Config struct {
+	Encodings   []string
+	Types       []string
+	MinSize     uint
+	CacheSize   uint
+	BrotliLevel int
+	ZstdLevel   int
+	GzipLevel   int
+	Enabled     bool
+	// Gzip is the deprecated option of the former gzip only compression, disabling compression when false.
+	Gzip *bool
+}
+
+func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
+	var config Config
+
+	flags.New("Compress", "Enable compression of responses").Prefix(prefix).DocPrefix("compress").BoolVar(fs, &config.Enabled, true, overrides)
+	flags.New("CompressEncodings", "Encodings used, by order of preference when the client accepts many").Prefix(prefix).DocPrefix("compress").StringSliceVar(fs, &config.Encodings, []string{Brotli, Zstd, Gzip}, overrides)
+	flags.New("CompressTypes", "Content-Types compressed, type/* matching a whole type").Prefix(prefix).DocPrefix("compress").StringSliceVar(fs, &config.Types, []string{
+		"text/html", "text/css", "text/plain", "text/javascript", "text/xml", "text/csv",
+		"application/javascript", "application/json", "application/ld+json", "application/manifest+json",
+		"application/xml", "application/rss+xml", "application/atom+xml", "application/wasm",
+		"image/svg+xml", "image/x-icon", "font/ttf", "font/otf",
+	}, overrides)
+	flags.New("CompressMinSize", "Minimum size in bytes of a compressed response").Prefix(prefix).DocPrefix("compress").UintVar(fs, &config.MinSize, 1024, overrides)
+	flags.New("CompressCacheSize", "Maximum memory in bytes of compressed files kept in cache, 0 to disable").Prefix(prefix).DocPrefix("compress").UintVar(fs, &config.CacheSize, 32<<20, overrides)
+	flags.New("CompressBrotliLevel", "Brotli level, from 0 to 11").Prefix(prefix).DocPrefix("compress").IntVar(fs, &config.BrotliLevel, 5, overrides)
+	flags.New("CompressZstdLevel", "Zstd level, from 1 to 22").Prefix(prefix).DocPrefix("compress").IntVar(fs, &config.ZstdLevel, 3, overrides)
+	flags.New("CompressGzipLevel", "Gzip level, from 1 to 9").Prefix(prefix).DocPrefix("compress").IntVar(fs, &config.GzipLevel, 6, overrides)
+	config.Gzip = flags.New("Gzip", "Deprecated, use compress instead").Prefix(prefix).DocPrefix("gzip").Bool(fs, true, overrides)
+
+	return &config
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// Service compresses responses with the preferred encoding accepted by the client, only for allowed Content-Types
+// and for bodies large enough to be worth it.
+type Service struct {
+	pools     map[string]*sync.Pool
+	cache     *cache.Cache
+	types     []string
+	encodings []string
+	minSize   int
+	maxSize   int
+}
+
+func New(config *Config, registry *metrics.Registry) (*Service, error) {
+	gzipDisabled := config.Gzip != nil && !*config.Gzip
+	if gzipDisabled {
+		slog.Warn("gzip option is deprecated, use compress=false to disable compression")
+	}
+
+	if !config.Enabled || gzipDisabled {
+		return nil, nil
+	}
+
+	service := &Service{
+		pools:   make(map[string]*sync.Pool),
+		minSize: int(config.MinSize),
+		maxSize: int(config.CacheSize),
+		cache:   cache.New(config.CacheSize, registry, "compress"),
+	}
+
+	for _, contentType := range config.Types {
+		service.types = append(service.types, strings.ToLower(strings.TrimSpace(contentType)))
+	}
+
+	for _, encoding := range config.Encodings {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if slices.Contains(service.encodings, encoding) {
+			continue
+		}
+
+		pool, err := newPool(encoding, config)
+		if err != nil {
+			return nil, err
+		}
+
+		service.encodings = append(service.encodings, encoding)
+		service.pools[encoding] = pool
+	}
+
+	if len(service.encodings) == 0 {
+		return nil, errNoEncoding
+	}
+
+	return service, nil
+}
+
+func newPool(encoding string, config *Config) (*sync.Pool, error) {
+	switch encoding {
+	case Brotli:
+		if config.BrotliLevel < brotli.BestSpeed || config.BrotliLevel > brotli.BestCompression {
+			return nil, fmt.Errorf("invalid brotli level %d", config.BrotliLevel)
+		}
+
+		return &sync.Pool{New: func() any {
+			return brotli.NewWriterLevel(nil, config.BrotliLevel)
+		}}, nil
+
+	case Zstd:
+		if config.ZstdLevel < 1 || config.ZstdLevel > 22 {
+			return nil, fmt.Errorf("invalid zstd level %d", config.ZstdLevel)
+		}
+
+		options := []zstd.EOption{zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(config.ZstdLevel)), zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true)}
+		if _, err := zstd.NewWriter(nil, options...); err != nil {
+			return nil, fmt.Errorf("zstd: %w", err)
+		}
+
+		return &sync.Pool{New: func() any {
+			writer, _ := zstd.NewWriter(nil, options...)
+			return writer
+		}}, nil
+
+	case Gzip:
+		if config.GzipLevel < gzip.BestSpeed || config.GzipLevel > gzip.BestCompression {
+			return nil, fmt.Errorf("invalid gzip level %d", config.GzipLevel)
+		}
+
+		return &sync.Pool{New: func() any {
+			writer, _ := gzip.NewWriterLevel(nil, config.GzipLevel)
+			return writer
+		}}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown encoding `%s`, expecting br, zstd or gzip", encoding)
+	}
+}
+
+// Negotiate returns the encoding used for the given Accept-Encoding, empty for none. The highest quality wins, the
+// order of configuration breaking ties.
+func (s *Service) Negotiate(acceptEncoding string) string {
+	if s == nil {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 {
+			continue
+		}
+
+		quality := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				quality = parsed
+			}
+		}
+
+		qualities[name] = quality
+	}
+
+	var output string
+	var best float64
+
+	for _, encoding := range s.encodings {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality = qualities["*"]
+		}
+
+		if quality > best {
+			output, best = encoding, quality
+		}
+	}
+
+	return output
+}
+
+func (s *Service) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range s.types {
+		if allowed == mediaType {
+			return true
+		}
+
+		if kind, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, kind+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := &responseWriter{
+			ResponseWriter: w,
+			service:        s,
+			encoding:       s.Negotiate(r.Header.Get("Accept-Encoding")),
+			head:           r.Method == http.MethodHead,
+		}
+		defer writer.close()
+
+		next.ServeHTTP(writer, r)
+	})
+}
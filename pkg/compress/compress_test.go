@@ -0,0 +1,465 @@
+package compress
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestFlags(t *testing.T) {
+	cases := map[string]struct {
+		want string
+	}{
+		"simple": {
+			"Usage of simple:\n  -compress\n    \t[compress] Enable compression of responses ${SIMPLE_COMPRESS} (default true)\n  -compressBrotliLevel int\n    \t[compress] Brotli level, from 0 to 11 ${SIMPLE_COMPRESS_BROTLI_LEVEL} (default 5)\n  -compressCacheSize uint\n    \t[compress] Maximum memory in bytes of compressed files kept in cache, 0 to disable ${SIMPLE_COMPRESS_CACHE_SIZE} (default 33554432)\n  -compressEncodings string slice\n    \t[compress] Encodings used, by order of preference when the client accepts many ${SIMPLE_COMPRESS_ENCODINGS}, as a string slice, environment variable separated by \",\" (default [br, zstd, gzip])\n  -compressGzipLevel int\n    \t[compress] Gzip level, from 1 to 9 ${SIMPLE_COMPRESS_GZIP_LEVEL} (default 6)\n  -compressMinSize uint\n    \t[compress] Minimum size in bytes of a compressed response ${SIMPLE_COMPRESS_MIN_SIZE} (default 1024)\n  -compressTypes string slice\n    \t[compress] Content-Types compressed, type/* matching a whole type ${SIMPLE_COMPRESS_TYPES}, as a string slice, environment variable separated by \",\" (default [text/html, text/css, text/plain, text/javascript, text/xml, text/csv, application/javascript, application/json, application/ld+json, application/manifest+json, application/xml, application/rss+xml, application/atom+xml, application/wasm, image/svg+xml, image/x-icon, font/ttf, font/otf])\n  -compressZstdLevel int\n    \t[compress] Zstd level, from 1 to 22 ${SIMPLE_COMPRESS_ZSTD_LEVEL} (default 3)\n  -gzip\n    \t[gzip] Deprecated, use compress instead ${SIMPLE_GZIP} (default true)\n",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			fs := flag.NewFlagSet(intention, flag.ContinueOnError)
+			Flags(fs, "")
+
+			var writer strings.Builder
+			fs.SetOutput(&writer)
+			fs.Usage()
+
+			result := writer.String()
+
+			if result != tc.want {
+				t.Errorf("Flags() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func testConfig() Config {
+	return Config{
+		Enabled:     true,
+		Encodings:   []string{Brotli, Zstd, Gzip},
+		Types:       []string{"text/*", "application/json"},
+		MinSize:     64,
+		BrotliLevel: 5,
+		ZstdLevel:   3,
+		GzipLevel:   6,
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		update  func(*Config)
+		wantErr bool
+	}{
+		"valid": {
+			func(*Config) {},
+			false,
+		},
+		"disabled": {
+			func(config *Config) {
+				config.Enabled = false
+				config.Encodings = nil
+			},
+			false,
+		},
+		"unknown encoding": {
+			func(config *Config) {
+				config.Encodings = []string{"deflate"}
+			},
+			true,
+		},
+		"no encoding": {
+			func(config *Config) {
+				config.Encodings = nil
+			},
+			true,
+		},
+		"invalid brotli level": {
+			func(config *Config) {
+				config.BrotliLevel = 12
+			},
+			true,
+		},
+		"invalid zstd level": {
+			func(config *Config) {
+				config.ZstdLevel = 0
+			},
+			true,
+		},
+		"unused invalid level": {
+			func(config *Config) {
+				config.Encodings = []string{Gzip}
+				config.ZstdLevel = 0
+			},
+			false,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			config := testConfig()
+			tc.update(&config)
+
+			_, err := New(&config, nil)
+
+			if (err != nil) != tc.wantErr {
+				t.Errorf("New() error = %v, wantErr %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewDeprecatedGzip(t *testing.T) {
+	disabled := false
+
+	config := testConfig()
+	config.Gzip = &disabled
+
+	service, err := New(&config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if service != nil {
+		t.Error("New() = service, want nil when gzip is disabled")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	config := testConfig()
+
+	service, err := New(&config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		acceptEncoding string
+		want           string
+	}{
+		"none": {
+			"",
+			"",
+		},
+		"identity": {
+			"identity",
+			"",
+		},
+		"server preference": {
+			"gzip, deflate, br, zstd",
+			Brotli,
+		},
+		"quality": {
+			"br;q=0.5, gzip;q=0.8",
+			Gzip,
+		},
+		"refused": {
+			"br;q=0, zstd",
+			Zstd,
+		},
+		"wildcard": {
+			"gzip;q=0.5, *",
+			Brotli,
+		},
+		"case insensitive": {
+			"GZIP",
+			Gzip,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := service.Negotiate(tc.acceptEncoding); got != tc.want {
+				t.Errorf("Negotiate() = `%s`, want `%s`", got, tc.want)
+			}
+		})
+	}
+}
+
+func decode(t *testing.T, encoding string, body io.Reader) string {
+	t.Helper()
+
+	var reader io.Reader
+
+	switch encoding {
+	case Brotli:
+		reader = brotli.NewReader(body)
+
+	case Zstd:
+		decoder, err := zstd.NewReader(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer decoder.Close()
+
+		reader = decoder
+
+	case Gzip:
+		decoder, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reader = decoder
+
+	default:
+		reader = body
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestMiddleware(t *testing.T) {
+	config := testConfig()
+
+	service, err := New(&config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	large := strings.Repeat("<p>Hello World</p>", 10)
+
+	cases := map[string]struct {
+		handler        http.HandlerFunc
+		acceptEncoding string
+		wantEncoding   string
+		wantVary       string
+		wantEtag       string
+	}{
+		"brotli": {
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				_, _ = io.WriteString(w, large)
+			},
+			"gzip, br",
+			Brotli,
+			"Accept-Encoding",
+			"",
+		},
+		"zstd by chunks": {
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				for range 10 {
+					_, _ = io.WriteString(w, "<p>Hello World</p>")
+				}
+			},
+			"zstd",
+			Zstd,
+			"Accept-Encoding",
+			"",
+		},
+		"gzip sniffed": {
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Etag", `"abc"`)
+				_, _ = io.WriteString(w, large)
+			},
+			"gzip",
+			Gzip,
+			"Accept-Encoding",
+			`W/"abc"`,
+		},
+		"not accepted": {
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = io.WriteString(w, large)
+			},
+			"",
+			"",
+			"Accept-Encoding",
+			"",
+		},
+		"too small": {
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = io.WriteString(w, "<p>Hello World</p>")
+			},
+			"br",
+			"",
+			"Accept-Encoding",
+			"",
+		},
+		"type not allowed": {
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				_, _ = io.WriteString(w, large)
+			},
+			"br",
+			"",
+			"",
+			"",
+		},
+		"already encoded": {
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Header().Set("Content-Encoding", "identity")
+				_, _ = io.WriteString(w, large)
+			},
+			"br",
+			"identity",
+			"",
+			"",
+		},
+		"partial content": {
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = io.WriteString(w, large)
+			},
+			"br",
+			"",
+			"",
+			"",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set("Accept-Encoding", tc.acceptEncoding)
+
+			writer := httptest.NewRecorder()
+			service.Middleware(tc.handler).ServeHTTP(writer, request)
+
+			if got := writer.Header().Get("Content-Encoding"); got != tc.wantEncoding {
+				t.Errorf("Middleware() Content-Encoding = `%s`, want `%s`", got, tc.wantEncoding)
+			}
+
+			if got := writer.Header().Get("Vary"); got != tc.wantVary {
+				t.Errorf("Middleware() Vary = `%s`, want `%s`", got, tc.wantVary)
+			}
+
+			if got := writer.Header().Get("Etag"); len(tc.wantEtag) != 0 && got != tc.wantEtag {
+				t.Errorf("Middleware() Etag = `%s`, want `%s`", got, tc.wantEtag)
+			}
+
+			want := large
+			if intention == "too small" {
+				want = "<p>Hello World</p>"
+			}
+
+			if got := decode(t, writer.Header().Get("Content-Encoding"), writer.Body); got != want {
+				t.Errorf("Middleware() body = `%s`, want `%s`", got, want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareFlush(t *testing.T) {
+	config := testConfig()
+
+	service, err := New(&config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+
+	writer := httptest.NewRecorder()
+	service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush() = %v", err)
+		}
+
+		_, _ = io.WriteString(w, "event: change\n\n")
+	})).ServeHTTP(writer, request)
+
+	if !writer.Flushed {
+		t.Error("Middleware() did not flush")
+	}
+
+	if got := writer.Header().Get("Content-Encoding"); got != Gzip {
+		t.Errorf("Middleware() Content-Encoding = `%s`, want `%s`", got, Gzip)
+	}
+
+	if got := decode(t, Gzip, writer.Body); got != "event: change\n\n" {
+		t.Errorf("Middleware() body = `%s`", got)
+	}
+}
+
+func TestMiddlewareHead(t *testing.T) {
+	config := testConfig()
+
+	service, err := New(&config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		content      string
+		contentType  string
+		wantEncoding string
+	}{
+		"compressed": {
+			strings.Repeat("<p>Hello World</p>", 10),
+			"text/html",
+			Gzip,
+		},
+		"too small": {
+			"<p>Hello World</p>",
+			"text/html",
+			"",
+		},
+		"type not allowed": {
+			strings.Repeat("<p>Hello World</p>", 10),
+			"image/png",
+			"",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			handler := service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.Header().Set("Content-Length", strconv.Itoa(len(tc.content)))
+				w.WriteHeader(http.StatusOK)
+
+				if r.Method == http.MethodGet {
+					_, _ = io.WriteString(w, tc.content)
+				}
+			}))
+
+			responses := make(map[string]*httptest.ResponseRecorder)
+
+			for _, method := range []string{http.MethodGet, http.MethodHead} {
+				request := httptest.NewRequest(method, "/", nil)
+				request.Header.Set("Accept-Encoding", "gzip")
+
+				writer := httptest.NewRecorder()
+				handler.ServeHTTP(writer, request)
+
+				responses[method] = writer
+			}
+
+			get, head := responses[http.MethodGet], responses[http.MethodHead]
+
+			if got := head.Header().Get("Content-Encoding"); got != tc.wantEncoding {
+				t.Errorf("Content-Encoding = `%s`, want `%s`", got, tc.wantEncoding)
+			}
+
+			if !reflect.DeepEqual(head.Header(), get.Header()) {
+				t.Errorf("HEAD headers = %+v, want %+v", head.Header(), get.Header())
+			}
+
+			if head.Body.Len() != 0 {
+				t.Errorf("HEAD body has %d bytes, want none", head.Body.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package compress
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// responseWriter buffers the beginning of the body until the minimum size is reached, to decide if compressing it is
+// worth it.
+type responseWriter struct {
+	http.ResponseWriter
+	service  *Service
+	encoder  encoder
+	encoding string
+	buffer   []byte
+	status   int
+	started  bool
+	head     bool
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.started || rw.status != 0 {
+		return
+	}
+
+	// Informational responses, e.g. Early Hints, are sent as is and don't end the headers
+	if status < http.StatusOK {
+		rw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	rw.status = status
+
+	if !rw.eligible() {
+		rw.start(false)
+		return
+	}
+
+	if size, err := strconv.Atoi(rw.Header().Get("Content-Length")); err == nil && size < rw.service.minSize {
+		rw.start(false)
+		return
+	}
+
+	// A HEAD response has no body to wait for, its headers are the ones of the GET, decided on the Content-Type
+	if rw.head {
+		rw.start(len(rw.Header().Get("Content-Type")) != 0 && rw.eligible())
+	}
+}
+
+func (rw *responseWriter) Write(content []byte) (int, error) {
+	if !rw.started {
+		if rw.status == 0 {
+			rw.WriteHeader(http.StatusOK)
+		}
+
+		if !rw.started {
+			rw.buffer = append(rw.buffer, content...)
+			if len(rw.buffer) >= rw.service.minSize {
+				rw.start(rw.eligible())
+			}
+
+			return len(content), nil
+		}
+	}
+
+	if rw.encoder != nil {
+		return rw.encoder.Write(content)
+	}
+
+	return rw.ResponseWriter.Write(content)
+}
+
+// eligible checks that the response can be compressed, Content-Type being sniffed if not set.
+func (rw *responseWriter) eligible() bool {
+	switch rw.status {
+	case http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+
+	header := rw.Header()
+
+	if len(header.Get("Content-Encoding")) != 0 || len(header.Get("Content-Range")) != 0 {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	if len(contentType) == 0 {
+		if len(rw.buffer) == 0 {
+			return true
+		}
+
+		contentType = http.DetectContentType(rw.buffer)
+		header.Set("Content-Type", contentType)
+	}
+
+	if !rw.service.allowed(contentType) {
+		return false
+	}
+
+	// The representation depends on Accept-Encoding, even for a client that doesn't accept any
+	if !varyOn(header, "Accept-Encoding") {
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	return len(rw.encoding) != 0
+}
+
+// start sends the headers and the buffered content.
+func (rw *responseWriter) start(compress bool) {
+	rw.started = true
+
+	if compress {
+		header := rw.Header()
+
+		header.Set("Content-Encoding", rw.encoding)
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+
+		// Encodings of a same content are equivalent, but not byte for byte identical
+		if etag := header.Get("Etag"); len(etag) != 0 && !strings.HasPrefix(etag, "W/") {
+			header.Set("Etag", "W/"+etag)
+		}
+
+		if !rw.head {
+			rw.encoder = rw.service.pools[rw.encoding].Get().(encoder)
+			rw.encoder.Reset(rw.ResponseWriter)
+		}
+	}
+
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+
+	rw.ResponseWriter.WriteHeader(rw.status)
+
+	if len(rw.buffer) != 0 {
+		_, _ = rw.Write(rw.buffer)
+		rw.buffer = nil
+	}
+}
+
+func (rw *responseWriter) close() {
+	if !rw.started {
+		if rw.status == 0 && len(rw.buffer) == 0 {
+			return
+		}
+
+		rw.start(false)
+	}
+
+	if rw.encoder == nil {
+		return
+	}
+
+	_ = rw.encoder.Close()
+	rw.encoder.Reset(nil)
+	rw.service.pools[rw.encoding].Put(rw.encoder)
+	rw.encoder = nil
+}
+
+// Flush sends what is buffered, committing to compress the response if allowed, whatever its final size.
+func (rw *responseWriter) Flush() {
+	if !rw.started {
+		rw.start(rw.eligible())
+	}
+
+	if rw.encoder != nil {
+		_ = rw.encoder.Flush()
+	}
+
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func varyOn(header http.Header, name string) bool {
+	for _, value := range header.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), name) || strings.TrimSpace(field) == "*" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
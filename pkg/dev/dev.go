@@ -0,0 +1,241 @@
+package dev
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/ViBiOh/flags"
+)
+
+const (
+	EventsPath = "/_viws/events"
+
+	heartbeat = 15 * time.Second
+)
+
+var (
+	ErrHSTS = errors.New("development mode refused with HSTS enabled, disable it or force development mode")
+
+	script     = fmt.Sprintf(`new EventSource(%q).addEventListener("change",function(){location.reload()});`, EventsPath)
+	scriptTag  = []byte("<script>" + script + "</script>")
+	bodyEndTag = regexp.MustCompile(`(?i)</body>`)
+)
+
+type Config struct {
+	Poll    time.Duration
+	Enabled bool
+	Force   bool
+}
+
+func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
+	var config Config
+
+	flags.New("Dev", "Development mode: live reload of HTML on change and no caching, never in production").Prefix(prefix).DocPrefix("dev").BoolVar(fs, &config.Enabled, false, overrides)
+	flags.New("DevForce", "Allow development mode with HSTS enabled").Prefix(prefix).DocPrefix("dev").BoolVar(fs, &config.Force, false, overrides)
+	flags.New("DevPoll", "Interval to check the directory for changes in development mode").Prefix(prefix).DocPrefix("dev").DurationVar(fs, &config.Poll, 500*time.Millisecond, overrides)
+
+	return &config
+}
+
+// Service watches a directory and notifies browsers of changes through Server-Sent Events.
+type Service struct {
+	subscribers map[chan struct{}]struct{}
+	directory   string
+	poll        time.Duration
+	mutex       sync.Mutex
+}
+
+// New returns nil when development mode is disabled. It is refused when HSTS is on, a sign of a production setup,
+// unless forced.
+func New(config *Config, directory string, hsts bool) (*Service, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	if hsts && !config.Force {
+		return nil, ErrHSTS
+	}
+
+	return &Service{
+		directory:   directory,
+		poll:        config.Poll,
+		subscribers: make(map[chan struct{}]struct{}),
+	}, nil
+}
+
+// InjectScript adds the live reload script at the end of the body of an HTML document.
+func InjectScript(content []byte) []byte {
+	// Searched on the content itself, lowering it may change the length of some runes
+	matches := bodyEndTag.FindAllIndex(content, -1)
+	if len(matches) == 0 {
+		return append(content, scriptTag...)
+	}
+
+	index := matches[len(matches)-1][0]
+
+	output := make([]byte, 0, len(content)+len(scriptTag))
+	output = append(output, content[:index]...)
+	output = append(output, scriptTag...)
+
+	return append(output, content[index:]...)
+}
+
+// ScriptHash is the Content-Security-Policy source allowing the live reload script.
+func ScriptHash() string {
+	sum := sha256.Sum256([]byte(script))
+
+	return fmt.Sprintf("'sha256-%s'", base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+// Banner writes a colourful reminder that development mode is on.
+func Banner(w io.Writer, url string) {
+	_, _ = fmt.Fprintf(w, "\n\033[1;35m  viws\033[0m \033[33mdevelopment mode\033[0m\n\n  ➜ \033[1;36m%s\033[0m\n\n  \033[2mLive reload on change, caching disabled. Never use it in production.\033[0m\n\n", url)
+}
+
+// Start polls the directory for changes until context is done.
+func (s *Service) Start(ctx context.Context) {
+	if s == nil || s.poll <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.poll)
+	defer ticker.Stop()
+
+	previous, err := fingerprint(s.directory)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "fingerprint directory", slog.String("dir", s.directory), slog.Any("error", err))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			current, err := fingerprint(s.directory)
+			if err != nil {
+				slog.LogAttrs(ctx, slog.LevelError, "fingerprint directory", slog.String("dir", s.directory), slog.Any("error", err))
+				continue
+			}
+
+			if current != previous {
+				previous = current
+				slog.LogAttrs(ctx, slog.LevelInfo, "Change detected, reloading", slog.String("dir", s.directory))
+				s.notify()
+			}
+		}
+	}
+}
+
+// fingerprint summarizes names, sizes and modification times of every file of the directory.
+func fingerprint(directory string) (uint64, error) {
+	hasher := fnv.New64a()
+
+	err := filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(hasher, "%s|%d|%d\n", path, info.Size(), info.ModTime().UnixNano())
+
+		return nil
+	})
+
+	return hasher.Sum64(), err
+}
+
+func (s *Service) subscribe() chan struct{} {
+	events := make(chan struct{}, 1)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.subscribers[events] = struct{}{}
+
+	return events
+}
+
+func (s *Service) unsubscribe(events chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.subscribers, events)
+}
+
+func (s *Service) notify() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for events := range s.subscribers {
+		select {
+		case events <- struct{}{}:
+		default: // a change is already pending
+		}
+	}
+}
+
+// Handler streams a `change` event each time the directory changes.
+func (s *Service) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		controller := http.NewResponseController(w)
+
+		// The stream lasts as long as the page is open, it must outlive the server's write timeout.
+		_ = controller.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+
+		if err := controller.Flush(); err != nil {
+			slog.LogAttrs(r.Context(), slog.LevelError, "flush events", slog.Any("error", err))
+			return
+		}
+
+		events := s.subscribe()
+		defer s.unsubscribe(events)
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			var message string
+
+			select {
+			case <-r.Context().Done():
+				return
+
+			case <-ticker.C:
+				message = ": heartbeat\n\n"
+
+			case <-events:
+				message = "event: change\ndata: {}\n\n"
+			}
+
+			if _, err := io.WriteString(w, message); err != nil {
+				return
+			}
+
+			if err := controller.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
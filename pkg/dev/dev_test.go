@@ -0,0 +1,176 @@
+package dev
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlags(t *testing.T) {
+	cases := map[string]struct {
+		want string
+	}{
+		"simple": {
+			"Usage of simple:\n  -dev\n    \t[dev] Development mode: live reload of HTML on change and no caching, never in production ${SIMPLE_DEV}\n  -devForce\n    \t[dev] Allow development mode with HSTS enabled ${SIMPLE_DEV_FORCE}\n  -devPoll duration\n    \t[dev] Interval to check the directory for changes in development mode ${SIMPLE_DEV_POLL} (default 500ms)\n",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			fs := flag.NewFlagSet(intention, flag.ContinueOnError)
+			Flags(fs, "")
+
+			var writer strings.Builder
+			fs.SetOutput(&writer)
+			fs.Usage()
+
+			result := writer.String()
+
+			if result != tc.want {
+				t.Errorf("Flags() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		config  Config
+		hsts    bool
+		wantNil bool
+		wantErr error
+	}{
+		"disabled": {
+			Config{},
+			true,
+			true,
+			nil,
+		},
+		"refused with hsts": {
+			Config{Enabled: true},
+			true,
+			true,
+			ErrHSTS,
+		},
+		"forced": {
+			Config{Enabled: true, Force: true},
+			true,
+			false,
+			nil,
+		},
+		"without hsts": {
+			Config{Enabled: true},
+			false,
+			false,
+			nil,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			result, err := New(&tc.config, t.TempDir(), tc.hsts)
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("New() = %s, want %s", err, tc.wantErr)
+			}
+
+			if (result == nil) != tc.wantNil {
+				t.Errorf("New() = %v, want nil %t", result, tc.wantNil)
+			}
+		})
+	}
+}
+
+func TestInjectScript(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		want    string
+	}{
+		"body": {
+			"<html><body><h1>Hello</h1></BODY></html>",
+			"<html><body><h1>Hello</h1>" + string(scriptTag) + "</BODY></html>",
+		},
+		"runes changing length when lowered": {
+			"<html><body><p>İstanbul K</p></body></html>",
+			"<html><body><p>İstanbul K</p>" + string(scriptTag) + "</body></html>",
+		},
+		"fragment": {
+			"<h1>Hello</h1>",
+			"<h1>Hello</h1>" + string(scriptTag),
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if result := string(InjectScript([]byte(tc.content))); result != tc.want {
+				t.Errorf("InjectScript() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	directory := t.TempDir()
+
+	service, err := New(&Config{Enabled: true, Poll: 10 * time.Millisecond}, directory, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go service.Start(ctx)
+
+	server := httptest.NewServer(service.Handler())
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if result := response.Header.Get("Content-Type"); result != "text/event-stream" {
+		t.Errorf("Content-Type = `%s`, want `text/event-stream`", result)
+	}
+
+	// Polling starts with the first tick, the change must happen after the initial fingerprint.
+	time.Sleep(50 * time.Millisecond)
+
+	if err := os.WriteFile(filepath.Join(directory, "index.html"), []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make(chan string, 10)
+
+	go func() {
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+
+		close(lines)
+	}()
+
+	select {
+	case line := <-lines:
+		if line != "event: change" {
+			t.Errorf("event = `%s`, want `event: change`", line)
+		}
+
+	case <-time.After(2 * time.Second):
+		t.Error("no change event received")
+	}
+}
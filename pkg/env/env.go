@@ -1,34 +1,145 @@
 package env
 
 import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/ViBiOh/flags"
-	"github.com/ViBiOh/httputils/v4/pkg/httpjson"
+	"github.com/ViBiOh/httputils/v4/pkg/hash"
+	"github.com/ViBiOh/httputils/v4/pkg/httperror"
 )
 
+const (
+	cacheControlHeader = "Cache-Control"
+	allowedMethods     = "GET, HEAD, OPTIONS"
+	bearerPrefix       = "Bearer "
+
+	FormatJSON       = "json"
+	FormatJavascript = "js"
+)
+
+var errUnauthorized = errors.New("invalid or missing bearer token")
+
 type Config struct {
-	Env []string
+	CacheControl string
+	Path         string
+	Format       string
+	Token        string
+	Env          []string
+	Endpoints    []string
 }
 
 func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
 	var config Config
 
 	flags.New("Env", "Environment variables to expose to expose").Prefix(prefix).DocPrefix("env").StringSliceVar(fs, &config.Env, nil, overrides)
+	flags.New("EnvCacheControl", "Cache-Control header of environment variables").Prefix(prefix).DocPrefix("env").StringVar(fs, &config.CacheControl, "no-cache", overrides)
+	flags.New("EnvPath", "Path of environment variables endpoint, empty to disable").Prefix(prefix).DocPrefix("env").StringVar(fs, &config.Path, "/env", overrides)
+	flags.New("EnvFormat", "Format of environment variables, 'json' or 'js' for a window.env script").Prefix(prefix).DocPrefix("env").StringVar(fs, &config.Format, FormatJSON, overrides)
+	flags.New("EnvToken", "Bearer token required to read environment variables").Prefix(prefix).DocPrefix("env").StringVar(fs, &config.Token, "", overrides)
+	flags.New("EnvEndpoint", "Additional endpoint of environment variables, as /path:KEY1+KEY2[:format[:token]]").Prefix(prefix).DocPrefix("env").StringSliceVar(fs, &config.Endpoints, nil, overrides)
 
 	return &config
 }
 
 type Service struct {
-	keys []string
+	cacheControl string
+	path         string
+	format       string
+	token        []byte
+	keys         []string
 }
 
-func New(config *Config) Service {
-	return Service{
-		keys: config.Env,
+func New(config *Config) (Service, error) {
+	switch config.Format {
+	case FormatJSON, FormatJavascript, "":
+	default:
+		return Service{}, fmt.Errorf("unknown format `%s`, expecting `%s` or `%s`", config.Format, FormatJSON, FormatJavascript)
+	}
+
+	service := Service{
+		keys:         config.Env,
+		cacheControl: config.CacheControl,
+		path:         config.Path,
+		format:       config.Format,
+	}
+
+	if len(config.Token) != 0 {
+		service.token = []byte(config.Token)
 	}
+
+	return service, nil
+}
+
+// NewEndpoints creates the endpoint of the flags and one per additional endpoint, each with its own path, keys,
+// format and token. Additional endpoints share the Cache-Control header.
+func NewEndpoints(config *Config) ([]Service, error) {
+	defaultService, err := New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	services := []Service{defaultService}
+	paths := map[string]bool{config.Path: true}
+
+	for _, value := range config.Endpoints {
+		endpoint, err := parseEndpoint(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if paths[endpoint.Path] {
+			return nil, fmt.Errorf("duplicate endpoint path `%s`", endpoint.Path)
+		}
+
+		paths[endpoint.Path] = true
+		endpoint.CacheControl = config.CacheControl
+
+		service, err := New(&endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint `%s`: %w", endpoint.Path, err)
+		}
+
+		services = append(services, service)
+	}
+
+	return services, nil
+}
+
+// parseEndpoint reads an endpoint as /path:KEY1+KEY2[:format[:token]], the token being last so that it can contain
+// colons.
+func parseEndpoint(value string) (Config, error) {
+	parts := strings.SplitN(value, ":", 4)
+	if len(parts) < 2 || !strings.HasPrefix(parts[0], "/") || len(parts[1]) == 0 {
+		return Config{}, fmt.Errorf("invalid endpoint `%s`, expecting /path:KEY1+KEY2[:format[:token]]", value)
+	}
+
+	config := Config{
+		Path: parts[0],
+		Env:  strings.Split(parts[1], "+"),
+	}
+
+	if len(parts) > 2 {
+		config.Format = parts[2]
+	}
+
+	if len(parts) > 3 {
+		config.Token = parts[3]
+	}
+
+	return config, nil
+}
+
+func (s Service) Path() string {
+	return s.path
 }
 
 func (s Service) Handler() http.Handler {
@@ -37,17 +148,109 @@ func (s Service) Handler() http.Handler {
 		env[key] = os.Getenv(key)
 	}
 
+	payload, contentType, err := s.encode(env)
+	if err != nil {
+		slog.Error("encode environment variables", "path", s.path, "error", err)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			httperror.InternalServerError(r.Context(), w, err)
+		})
+	}
+
+	etag := fmt.Sprintf(`"%s"`, hash.Hash(payload))
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
+		switch r.Method {
+		case http.MethodOptions:
+			w.Header().Set("Allow", allowedMethods)
 			w.WriteHeader(http.StatusOK)
 			return
-		}
 
-		if r.Method != http.MethodGet {
+		case http.MethodGet, http.MethodHead:
+
+		default:
+			w.Header().Set("Allow", allowedMethods)
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		httpjson.Write(r.Context(), w, http.StatusOK, env)
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httperror.Unauthorized(r.Context(), w, errUnauthorized)
+			return
+		}
+
+		if len(s.cacheControl) != 0 {
+			w.Header().Set(cacheControlHeader, s.cacheControl)
+		}
+		w.Header().Set("Etag", etag)
+
+		if len(s.token) != 0 {
+			w.Header().Add("Vary", "Authorization")
+		}
+
+		if etagMatch(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		w.WriteHeader(http.StatusOK)
+
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		if _, err := w.Write(payload); err != nil {
+			slog.LogAttrs(r.Context(), slog.LevelError, "write environment variables", slog.Any("error", err))
+		}
 	})
 }
+
+func (s Service) encode(env map[string]string) ([]byte, string, error) {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return nil, "", fmt.Errorf("marshal: %w", err)
+	}
+
+	switch s.format {
+	case FormatJSON, "":
+		return append(payload, '\n'), "application/json; charset=utf-8", nil
+
+	case FormatJavascript:
+		return fmt.Appendf(nil, "window.env = %s;\n", payload), "text/javascript; charset=utf-8", nil
+
+	default:
+		return nil, "", fmt.Errorf("unknown format `%s`", s.format)
+	}
+}
+
+func (s Service) authorized(r *http.Request) bool {
+	if len(s.token) == 0 {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), bearerPrefix)
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), s.token) == 1
+}
+
+func etagMatch(header, etag string) bool {
+	if len(header) == 0 {
+		return false
+	}
+
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -18,7 +18,7 @@ func TestFlags(t *testing.T) {
 		want string
 	}{
 		"simple": {
-			"Usage of simple:\n  -env string slice\n    \t[env] Environment variables to expose to expose ${SIMPLE_ENV}, as a string slice, environment variable separated by \",\"\n",
+			"Usage of simple:\n  -env string slice\n    \t[env] Environment variables to expose to expose ${SIMPLE_ENV}, as a string slice, environment variable separated by \",\"\n  -envCacheControl string\n    \t[env] Cache-Control header of environment variables ${SIMPLE_ENV_CACHE_CONTROL} (default \"no-cache\")\n  -envEndpoint string slice\n    \t[env] Additional endpoint of environment variables, as /path:KEY1+KEY2[:format[:token]] ${SIMPLE_ENV_ENDPOINT}, as a string slice, environment variable separated by \",\"\n  -envFormat string\n    \t[env] Format of environment variables, 'json' or 'js' for a window.env script ${SIMPLE_ENV_FORMAT} (default \"json\")\n  -envPath string\n    \t[env] Path of environment variables endpoint, empty to disable ${SIMPLE_ENV_PATH} (default \"/env\")\n  -envToken string\n    \t[env] Bearer token required to read environment variables ${SIMPLE_ENV_TOKEN}\n",
 		},
 	}
 
@@ -42,12 +42,14 @@ func TestFlags(t *testing.T) {
 
 func TestNew(t *testing.T) {
 	cases := map[string]struct {
-		input Config
-		want  []string
+		input   Config
+		want    []string
+		wantErr bool
 	}{
 		"should work with empty values": {
 			Config{},
 			nil,
+			false,
 		},
 		"should work with env value": {
 			Config{
@@ -58,67 +60,206 @@ func TestNew(t *testing.T) {
 				"BASH",
 				"VERSION",
 			},
+			false,
+		},
+		"should refuse unknown format": {
+			Config{
+				Format: "yaml",
+			},
+			nil,
+			true,
 		},
 	}
 
 	for intention, tc := range cases {
 		t.Run(intention, func(t *testing.T) {
-			if result := New(&tc.input); !reflect.DeepEqual(result.keys, tc.want) {
+			result, err := New(&tc.input)
+
+			if (err != nil) != tc.wantErr {
+				t.Errorf("New() error = %v, wantErr %t", err, tc.wantErr)
+			}
+
+			if !reflect.DeepEqual(result.keys, tc.want) {
 				t.Errorf("New() = %+v, want %+v", result.keys, tc.want)
 			}
 		})
 	}
 }
 
+func TestNewEndpoints(t *testing.T) {
+	cases := map[string]struct {
+		input   Config
+		want    []string
+		wantErr bool
+	}{
+		"should work without additional endpoint": {
+			Config{Path: "/env"},
+			[]string{"/env"},
+			false,
+		},
+		"should create each endpoint": {
+			Config{Path: "/env", Endpoints: []string{"/env/internal:BUILD_ID+FEATURES:json:secret", "/env.js:API_URL:js"}},
+			[]string{"/env", "/env/internal", "/env.js"},
+			false,
+		},
+		"should refuse endpoint without keys": {
+			Config{Path: "/env", Endpoints: []string{"/env/internal"}},
+			nil,
+			true,
+		},
+		"should refuse relative path": {
+			Config{Path: "/env", Endpoints: []string{"env/internal:BUILD_ID"}},
+			nil,
+			true,
+		},
+		"should refuse duplicate path": {
+			Config{Path: "/env", Endpoints: []string{"/env:BUILD_ID"}},
+			nil,
+			true,
+		},
+		"should refuse unknown format": {
+			Config{Path: "/env", Endpoints: []string{"/env/internal:BUILD_ID:yaml"}},
+			nil,
+			true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			result, err := NewEndpoints(&tc.input)
+
+			if (err != nil) != tc.wantErr {
+				t.Errorf("NewEndpoints() error = %v, wantErr %t", err, tc.wantErr)
+			}
+
+			var paths []string
+			for _, service := range result {
+				paths = append(paths, service.Path())
+			}
+
+			if !reflect.DeepEqual(paths, tc.want) {
+				t.Errorf("NewEndpoints() = %v, want %v", paths, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	result, err := parseEndpoint("/env/internal:BUILD_ID+FEATURES:json:se:cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Config{Path: "/env/internal", Env: []string{"BUILD_ID", "FEATURES"}, Format: FormatJSON, Token: "se:cret"}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("parseEndpoint() = %+v, want %+v", result, want)
+	}
+}
+
 func TestHandler(t *testing.T) {
 	user := os.Getenv("USER")
 	_ = os.Setenv("ESCAPE", `it's a "test"`)
 
 	cases := map[string]struct {
 		request    *http.Request
-		env        []string
+		config     Config
 		want       string
 		wantStatus int
 	}{
 		"should respond to OPTIONS request": {
 			httptest.NewRequest(http.MethodOptions, "/", nil),
-			nil,
+			Config{},
 			"",
 			http.StatusOK,
 		},
-		"should reject non GET/OPTIONS request": {
+		"should reject non GET/HEAD/OPTIONS request": {
 			httptest.NewRequest(http.MethodPost, "/", nil),
-			nil,
+			Config{},
 			"",
 			http.StatusMethodNotAllowed,
 		},
 		"should return empty JSON if no key": {
 			httptest.NewRequest(http.MethodGet, "/", nil),
-			nil,
+			Config{},
 			"{}\n",
 			http.StatusOK,
 		},
 		"should return asked keys": {
 			httptest.NewRequest(http.MethodGet, "/", nil),
-			[]string{"USER", "ESCAPE"},
+			Config{Env: []string{"USER", "ESCAPE"}},
 			fmt.Sprintf("{\"ESCAPE\":\"it's a \\\"test\\\"\",\"USER\":\"%s\"}\n", user),
 			http.StatusOK,
 		},
 		"should return empty value for not found keys": {
 			httptest.NewRequest(http.MethodGet, "/", nil),
-			[]string{"USER", "UNKNOWN_ENV_VAR"},
+			Config{Env: []string{"USER", "UNKNOWN_ENV_VAR"}},
 			fmt.Sprintf("{\"UNKNOWN_ENV_VAR\":\"\",\"USER\":\"%s\"}\n", user),
 			http.StatusOK,
 		},
+		"should respond to HEAD request without body": {
+			httptest.NewRequest(http.MethodHead, "/", nil),
+			Config{Env: []string{"USER"}},
+			"",
+			http.StatusOK,
+		},
+		"should respond not modified on matching etag": {
+			func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("If-None-Match", `W/"other", *`)
+
+				return req
+			}(),
+			Config{Env: []string{"USER"}},
+			"",
+			http.StatusNotModified,
+		},
+		"should reject request without token": {
+			httptest.NewRequest(http.MethodGet, "/", nil),
+			Config{Env: []string{"USER"}, Token: "secret"},
+			"invalid or missing bearer token\n",
+			http.StatusUnauthorized,
+		},
+		"should reject request with invalid token": {
+			func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Authorization", "Bearer guess")
+
+				return req
+			}(),
+			Config{Env: []string{"USER"}, Token: "secret"},
+			"invalid or missing bearer token\n",
+			http.StatusUnauthorized,
+		},
+		"should return keys with valid token": {
+			func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Authorization", "Bearer secret")
+
+				return req
+			}(),
+			Config{Env: []string{"USER"}, Token: "secret"},
+			fmt.Sprintf("{\"USER\":\"%s\"}\n", user),
+			http.StatusOK,
+		},
+		"should return javascript format": {
+			httptest.NewRequest(http.MethodGet, "/", nil),
+			Config{Env: []string{"USER"}, Format: FormatJavascript},
+			fmt.Sprintf("window.env = {\"USER\":\"%s\"};\n", user),
+			http.StatusOK,
+		},
 	}
 
 	for intention, tc := range cases {
 		t.Run(intention, func(t *testing.T) {
 			writer := httptest.NewRecorder()
 
-			a := New(&Config{
-				Env: tc.env,
-			})
+			tc.config.CacheControl = "no-cache"
+
+			a, err := New(&tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
 			a.Handler().ServeHTTP(writer, tc.request)
 
 			if result := writer.Code; result != tc.wantStatus {
@@ -128,6 +269,16 @@ func TestHandler(t *testing.T) {
 			if result, _ := request.ReadBodyResponse(writer.Result()); string(result) != tc.want {
 				t.Errorf("Handler() = `%s`, want `%s`", string(result), tc.want)
 			}
+
+			if tc.wantStatus == http.StatusOK && tc.request.Method != http.MethodOptions {
+				if result := writer.Header().Get("Cache-Control"); result != "no-cache" {
+					t.Errorf("Handler() Cache-Control = `%s`, want `%s`", result, "no-cache")
+				}
+
+				if result := writer.Header().Get("Etag"); len(result) == 0 {
+					t.Error("Handler() Etag is empty")
+				}
+			}
 		})
 	}
 }
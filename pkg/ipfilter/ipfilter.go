@@ -0,0 +1,254 @@
+package ipfilter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ViBiOh/flags"
+	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+	"github.com/ViBiOh/viws/pkg/clientip"
+	"github.com/ViBiOh/viws/pkg/metrics"
+)
+
+const (
+	allowAction = "allow"
+	denyAction  = "deny"
+)
+
+type Config struct {
+	File   string
+	Allow  []string
+	Deny   []string
+	Reload time.Duration
+}
+
+func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
+	var config Config
+
+	flags.New("IpAllow", "CIDR allowed, optionally scoped to a path prefix as /prefix/=CIDR, others being denied").Prefix(prefix).DocPrefix("ipFilter").StringSliceVar(fs, &config.Allow, nil, overrides)
+	flags.New("IpDeny", "CIDR denied, optionally scoped to a path prefix as /prefix/=CIDR").Prefix(prefix).DocPrefix("ipFilter").StringSliceVar(fs, &config.Deny, nil, overrides)
+	flags.New("IpFile", "File of rules, one 'allow|deny CIDR [prefix]' per line, reloaded on change").Prefix(prefix).DocPrefix("ipFilter").StringVar(fs, &config.File, "", overrides)
+	flags.New("IpFileReload", "Interval to check the rules file for changes").Prefix(prefix).DocPrefix("ipFilter").DurationVar(fs, &config.Reload, 30*time.Second, overrides)
+
+	return &config
+}
+
+type rule struct {
+	network *net.IPNet
+	prefix  string
+	allow   bool
+}
+
+// Service filters requests by client IP. Every matching deny rule rejects the request. When allow rules match the
+// path, only those of the longest prefix apply and the client must be in one of them.
+type Service struct {
+	rules    atomic.Pointer[[]rule]
+	modTime  atomic.Pointer[time.Time]
+	denied   *metrics.Counter
+	static   []rule
+	file     string
+	resolver clientip.Resolver
+	reload   time.Duration
+}
+
+func New(config *Config, resolver clientip.Resolver, registry *metrics.Registry) (*Service, error) {
+	if len(config.Allow) == 0 && len(config.Deny) == 0 && len(config.File) == 0 {
+		return nil, nil
+	}
+
+	service := &Service{
+		file:     config.File,
+		reload:   config.Reload,
+		resolver: resolver,
+		denied:   registry.Counter("viws_ip_denied_total", "Requests rejected by IP filter"),
+	}
+
+	for _, list := range []struct {
+		values []string
+		allow  bool
+	}{{config.Allow, true}, {config.Deny, false}} {
+		for _, value := range list.values {
+			prefix, cidr, ok := strings.Cut(value, "=")
+			if !ok {
+				prefix, cidr = "/", value
+			}
+
+			current, err := newRule(list.allow, cidr, prefix)
+			if err != nil {
+				return nil, err
+			}
+
+			service.static = append(service.static, current)
+		}
+	}
+
+	service.rules.Store(&service.static)
+
+	if len(service.file) != 0 {
+		if err := service.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return service, nil
+}
+
+func newRule(allow bool, cidr, prefix string) (rule, error) {
+	if !strings.HasPrefix(prefix, "/") {
+		return rule{}, fmt.Errorf("invalid prefix `%s`, it must start with /", prefix)
+	}
+
+	network, err := clientip.ParseNetwork(cidr)
+	if err != nil {
+		return rule{}, err
+	}
+
+	return rule{allow: allow, network: network, prefix: prefix}, nil
+}
+
+func parseRules(content []byte) ([]rule, error) {
+	var output []rule
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) > 3 || len(fields) < 2 || (fields[0] != allowAction && fields[0] != denyAction) {
+			return nil, fmt.Errorf("line %d: expecting `allow|deny CIDR [prefix]`", line)
+		}
+
+		prefix := "/"
+		if len(fields) == 3 {
+			prefix = fields[2]
+		}
+
+		current, err := newRule(fields[0] == allowAction, fields[1], prefix)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		output = append(output, current)
+	}
+
+	return output, scanner.Err()
+}
+
+// load reads the rules file if it changed since last load, previous rules being kept on error.
+func (s *Service) load() error {
+	info, err := os.Stat(s.file)
+	if err != nil {
+		return fmt.Errorf("stat rules: %w", err)
+	}
+
+	if previous := s.modTime.Load(); previous != nil && previous.Equal(info.ModTime()) {
+		return nil
+	}
+
+	content, err := os.ReadFile(s.file)
+	if err != nil {
+		return fmt.Errorf("read rules: %w", err)
+	}
+
+	fileRules, err := parseRules(content)
+	if err != nil {
+		return fmt.Errorf("parse rules: %w", err)
+	}
+
+	rules := append(append(make([]rule, 0, len(s.static)+len(fileRules)), s.static...), fileRules...)
+
+	modTime := info.ModTime()
+	s.modTime.Store(&modTime)
+	s.rules.Store(&rules)
+
+	slog.Info("IP rules loaded", "file", s.file, "count", len(fileRules))
+
+	return nil
+}
+
+// Start reloads the rules file on change until context is done.
+func (s *Service) Start(ctx context.Context) {
+	if s == nil || len(s.file) == 0 || s.reload <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.reload)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := s.load(); err != nil {
+				slog.LogAttrs(ctx, slog.LevelError, "reload IP rules", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+func (s *Service) Allowed(ip net.IP, path string) bool {
+	if ip == nil {
+		return false
+	}
+
+	var allowPrefix string
+	allowed := true
+
+	for _, current := range *s.rules.Load() {
+		if !strings.HasPrefix(path, current.prefix) {
+			continue
+		}
+
+		if !current.allow {
+			if current.network.Contains(ip) {
+				return false
+			}
+
+			continue
+		}
+
+		switch {
+		case len(current.prefix) > len(allowPrefix):
+			allowPrefix = current.prefix
+			allowed = current.network.Contains(ip)
+
+		case len(current.prefix) == len(allowPrefix):
+			allowed = allowed || current.network.Contains(ip)
+		}
+	}
+
+	return allowed
+}
+
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Allowed(s.resolver.IP(r), r.URL.Path) {
+			s.denied.Inc()
+			httperror.Forbidden(r.Context(), w)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,215 @@
+package ipfilter
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/viws/pkg/clientip"
+)
+
+func TestFlags(t *testing.T) {
+	cases := map[string]struct {
+		want string
+	}{
+		"simple": {
+			"Usage of simple:\n  -ipAllow string slice\n    \t[ipFilter] CIDR allowed, optionally scoped to a path prefix as /prefix/=CIDR, others being denied ${SIMPLE_IP_ALLOW}, as a string slice, environment variable separated by \",\"\n  -ipDeny string slice\n    \t[ipFilter] CIDR denied, optionally scoped to a path prefix as /prefix/=CIDR ${SIMPLE_IP_DENY}, as a string slice, environment variable separated by \",\"\n  -ipFile string\n    \t[ipFilter] File of rules, one 'allow|deny CIDR [prefix]' per line, reloaded on change ${SIMPLE_IP_FILE}\n  -ipFileReload duration\n    \t[ipFilter] Interval to check the rules file for changes ${SIMPLE_IP_FILE_RELOAD} (default 30s)\n",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			fs := flag.NewFlagSet(intention, flag.ContinueOnError)
+			Flags(fs, "")
+
+			var writer strings.Builder
+			fs.SetOutput(&writer)
+			fs.Usage()
+
+			result := writer.String()
+
+			if result != tc.want {
+				t.Errorf("Flags() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	cases := map[string]struct {
+		content   string
+		wantCount int
+		wantErr   bool
+	}{
+		"empty": {
+			"",
+			0,
+			false,
+		},
+		"comments": {
+			"# office\nallow 10.8.0.0/16 /admin/ # vpn\n\ndeny 192.0.2.66\n",
+			2,
+			false,
+		},
+		"unknown action": {
+			"block 10.0.0.0/8",
+			0,
+			true,
+		},
+		"invalid cidr": {
+			"allow 10.0.0.0/33",
+			0,
+			true,
+		},
+		"invalid prefix": {
+			"allow 10.0.0.0/8 admin",
+			0,
+			true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			result, err := parseRules([]byte(tc.content))
+
+			if (err != nil) != tc.wantErr {
+				t.Errorf("parseRules() = %s, want error %t", err, tc.wantErr)
+			}
+
+			if len(result) != tc.wantCount {
+				t.Errorf("parseRules() = %d rules, want %d", len(result), tc.wantCount)
+			}
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	service, err := New(&Config{
+		Allow: []string{"/admin/=10.8.0.0/16", "/admin/=192.0.2.10", "/admin/public/=0.0.0.0/0"},
+		Deny:  []string{"198.51.100.0/24"},
+	}, clientip.Resolver{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		ip   string
+		path string
+		want bool
+	}{
+		"public": {
+			"203.0.113.1",
+			"/index.html",
+			true,
+		},
+		"denied everywhere": {
+			"198.51.100.7",
+			"/index.html",
+			false,
+		},
+		"admin from vpn": {
+			"10.8.1.2",
+			"/admin/users",
+			true,
+		},
+		"admin from second allowed": {
+			"192.0.2.10",
+			"/admin/",
+			true,
+		},
+		"admin from outside": {
+			"203.0.113.1",
+			"/admin/users",
+			false,
+		},
+		"longest prefix": {
+			"203.0.113.1",
+			"/admin/public/logo.png",
+			true,
+		},
+		"deny wins": {
+			"198.51.100.7",
+			"/admin/public/logo.png",
+			false,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if result := service.Allowed(net.ParseIP(tc.ip), tc.path); result != tc.want {
+				t.Errorf("Allowed() = %t, want %t", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rules")
+
+	if err := os.WriteFile(filename, []byte("allow 10.0.0.0/8\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := New(&Config{File: filename}, clientip.Resolver{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	call := func() int {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.RemoteAddr = "192.0.2.1:1234"
+
+		writer := httptest.NewRecorder()
+		handler.ServeHTTP(writer, request)
+
+		return writer.Code
+	}
+
+	if result := call(); result != http.StatusForbidden {
+		t.Errorf("before reload = %d, want %d", result, http.StatusForbidden)
+	}
+
+	if err := os.WriteFile(filename, []byte("allow 10.0.0.0/8\nallow 192.0.2.0/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filename, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := call(); result != http.StatusNoContent {
+		t.Errorf("after reload = %d, want %d", result, http.StatusNoContent)
+	}
+
+	if err := os.WriteFile(filename, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(filename, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.load(); err == nil {
+		t.Error("load() = nil, want error")
+	}
+
+	if result := call(); result != http.StatusNoContent {
+		t.Errorf("after invalid reload = %d, want %d", result, http.StatusNoContent)
+	}
+}
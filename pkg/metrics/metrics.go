@@ -0,0 +1,317 @@
+package metrics
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ViBiOh/flags"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, of latency histograms.
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type Config struct {
+	Path     string
+	Separate bool
+}
+
+func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
+	var config Config
+
+	flags.New("MetricsPath", "Path of Prometheus metrics endpoint, empty to disable").Prefix(prefix).DocPrefix("metrics").StringVar(fs, &config.Path, "", overrides)
+	flags.New("MetricsSeparate", "Serve Prometheus metrics on the metrics listener instead of the main one").Prefix(prefix).DocPrefix("metrics").BoolVar(fs, &config.Separate, false, overrides)
+
+	return &config
+}
+
+// Registry holds metrics and writes them in the Prometheus text format. A nil Registry hands out nil metrics, that
+// are no-op, so instrumented code doesn't have to check if metrics are enabled.
+type Registry struct {
+	families map[string]*family
+	path     string
+	separate bool
+	mutex    sync.RWMutex
+}
+
+type family struct {
+	series     map[string]writer
+	name       string
+	help       string
+	metricType string
+}
+
+type writer interface {
+	write(w io.Writer, name, labels string)
+}
+
+func New(config *Config) *Registry {
+	if len(config.Path) == 0 {
+		return nil
+	}
+
+	return &Registry{
+		path:     config.Path,
+		separate: config.Separate,
+		families: make(map[string]*family),
+	}
+}
+
+func (r *Registry) Path() string {
+	if r == nil {
+		return ""
+	}
+
+	return r.path
+}
+
+// Separate indicates if metrics are served on a dedicated listener.
+func (r *Registry) Separate() bool {
+	return r != nil && r.separate
+}
+
+// Counter returns the counter of the given name and labels, given as key and value pairs, creating it if needed.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	if r == nil {
+		return nil
+	}
+
+	return register(r, name, help, "counter", labels, func() *Counter { return &Counter{} })
+}
+
+// Gauge returns the gauge of the given name and labels, given as key and value pairs, creating it if needed.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	if r == nil {
+		return nil
+	}
+
+	return register(r, name, help, "gauge", labels, func() *Gauge { return &Gauge{} })
+}
+
+// Histogram returns the histogram of the given name and labels, given as key and value pairs, creating it if needed.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if r == nil {
+		return nil
+	}
+
+	return register(r, name, help, "histogram", labels, func() *Histogram {
+		return &Histogram{
+			buckets: buckets,
+			counts:  make([]atomic.Uint64, len(buckets)),
+		}
+	})
+}
+
+func register[T writer](r *Registry, name, help, metricType string, labels []string, create func() T) T {
+	key := formatLabels(labels)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	current, ok := r.families[name]
+	if !ok {
+		current = &family{name: name, help: help, metricType: metricType, series: make(map[string]writer)}
+		r.families[name] = current
+	} else if current.metricType != metricType {
+		panic(fmt.Sprintf("metric `%s` already registered as a %s", name, current.metricType))
+	}
+
+	if series, ok := current.series[key]; ok {
+		return series.(T)
+	}
+
+	series := create()
+	current.series[key] = series
+
+	return series
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	if len(labels)%2 != 0 {
+		panic("labels must be given as key and value pairs")
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", labels[i], strconv.Quote(labels[i+1])))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// Write outputs every metric in the Prometheus text format, sorted by name then labels.
+func (r *Registry) Write(w io.Writer) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		current := r.families[name]
+
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n", current.name, escapeHelp(current.help))
+		_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", current.name, current.metricType)
+
+		keys := make([]string, 0, len(current.series))
+		for key := range current.series {
+			keys = append(keys, key)
+		}
+
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			current.series[key].write(w, current.name, key)
+		}
+	}
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var builder strings.Builder
+		r.Write(&builder)
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+
+		if _, err := io.WriteString(w, builder.String()); err != nil {
+			slog.LogAttrs(req.Context(), slog.LevelError, "write metrics", slog.Any("error", err))
+		}
+	})
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func writeSample(w io.Writer, name, labels string, value float64) {
+	if len(labels) != 0 {
+		_, _ = fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(value))
+	} else {
+		_, _ = fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+	}
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func joinLabels(labels, extra string) string {
+	if len(labels) == 0 {
+		return extra
+	}
+
+	return labels + "," + extra
+}
+
+type Counter struct {
+	value atomic.Uint64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Add(delta uint64) {
+	if c != nil {
+		c.value.Add(delta)
+	}
+}
+
+func (c *Counter) Value() uint64 {
+	if c == nil {
+		return 0
+	}
+
+	return c.value.Load()
+}
+
+func (c *Counter) write(w io.Writer, name, labels string) {
+	writeSample(w, name, labels, float64(c.value.Load()))
+}
+
+type Gauge struct {
+	value atomic.Int64
+}
+
+func (g *Gauge) Set(value int64) {
+	if g != nil {
+		g.value.Store(value)
+	}
+}
+
+func (g *Gauge) Add(delta int64) {
+	if g != nil {
+		g.value.Add(delta)
+	}
+}
+
+func (g *Gauge) write(w io.Writer, name, labels string) {
+	writeSample(w, name, labels, float64(g.value.Load()))
+}
+
+// Histogram counts observations per bucket, the sum being stored as float64 bits to be updated atomically.
+type Histogram struct {
+	buckets []float64
+	counts  []atomic.Uint64
+	count   atomic.Uint64
+	sum     atomic.Uint64 // float64 bits
+}
+
+func (h *Histogram) Observe(value float64) {
+	if h == nil {
+		return
+	}
+
+	if index, _ := slices.BinarySearch(h.buckets, value); index < len(h.counts) {
+		h.counts[index].Add(1)
+	}
+
+	h.count.Add(1)
+
+	for {
+		previous := h.sum.Load()
+		if h.sum.CompareAndSwap(previous, math.Float64bits(math.Float64frombits(previous)+value)) {
+			return
+		}
+	}
+}
+
+func (h *Histogram) write(w io.Writer, name, labels string) {
+	var cumulative uint64
+
+	for index, bound := range h.buckets {
+		cumulative += h.counts[index].Load()
+		writeSample(w, name+"_bucket", joinLabels(labels, fmt.Sprintf(`le="%s"`, formatFloat(bound))), float64(cumulative))
+	}
+
+	// Observations may happen while writing, the total is never lower than the last bucket.
+	count := max(h.count.Load(), cumulative)
+
+	writeSample(w, name+"_bucket", joinLabels(labels, `le="+Inf"`), float64(count))
+	writeSample(w, name+"_sum", labels, math.Float64frombits(h.sum.Load()))
+	writeSample(w, name+"_count", labels, float64(count))
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlags(t *testing.T) {
+	cases := map[string]struct {
+		want string
+	}{
+		"simple": {
+			"Usage of simple:\n  -metricsPath string\n    \t[metrics] Path of Prometheus metrics endpoint, empty to disable ${SIMPLE_METRICS_PATH}\n  -metricsSeparate\n    \t[metrics] Serve Prometheus metrics on the metrics listener instead of the main one ${SIMPLE_METRICS_SEPARATE}\n",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			fs := flag.NewFlagSet(intention, flag.ContinueOnError)
+			Flags(fs, "")
+
+			var writer strings.Builder
+			fs.SetOutput(&writer)
+			fs.Usage()
+
+			result := writer.String()
+
+			if result != tc.want {
+				t.Errorf("Flags() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestNil(t *testing.T) {
+	var registry *Registry
+
+	registry.Counter("requests_total", "Requests").Inc()
+	registry.Gauge("in_flight", "In flight").Add(1)
+	registry.Histogram("duration_seconds", "Duration", DefaultBuckets).Observe(1)
+
+	if result := registry.Path(); len(result) != 0 {
+		t.Errorf("Path() = `%s`, want empty", result)
+	}
+
+	if registry.Separate() {
+		t.Error("Separate() = true, want false")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	registry := New(&Config{Path: "/metrics"})
+
+	registry.Counter("requests_total", "Requests served", "class", "2xx").Add(3)
+	registry.Counter("requests_total", "Requests served", "class", "4xx").Inc()
+	registry.Counter("requests_total", "Requests served", "class", "2xx").Inc()
+	registry.Gauge("in_flight", "Requests in flight").Set(2)
+
+	histogram := registry.Histogram("duration_seconds", "Duration of requests", []float64{0.1, 1})
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(2)
+
+	writer := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	want := `# HELP duration_seconds Duration of requests
+# TYPE duration_seconds histogram
+duration_seconds_bucket{le="0.1"} 1
+duration_seconds_bucket{le="1"} 2
+duration_seconds_bucket{le="+Inf"} 3
+duration_seconds_sum 2.55
+duration_seconds_count 3
+# HELP in_flight Requests in flight
+# TYPE in_flight gauge
+in_flight 2
+# HELP requests_total Requests served
+# TYPE requests_total counter
+requests_total{class="2xx"} 4
+requests_total{class="4xx"} 1
+`
+
+	if result := writer.Body.String(); result != want {
+		t.Errorf("Write() = `%s`, want `%s`", result, want)
+	}
+
+	if result := writer.Header().Get("Content-Type"); result != contentType {
+		t.Errorf("Content-Type = `%s`, want `%s`", result, contentType)
+	}
+}
@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/flags"
+)
+
+type Config struct {
+	Routes      []string
+	Headers     []string
+	Timeout     time.Duration
+	StripPrefix bool
+}
+
+func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
+	var config Config
+
+	flags.New("Proxy", "Path prefix proxied to an upstream, as /prefix=http://upstream:8080").Prefix(prefix).DocPrefix("proxy").StringSliceVar(fs, &config.Routes, nil, overrides)
+	flags.New("ProxyStripPrefix", "Remove the prefix from the path sent to the upstream").Prefix(prefix).DocPrefix("proxy").BoolVar(fs, &config.StripPrefix, true, overrides)
+	flags.New("ProxyHeader", "Header set on requests sent to upstreams e.g. x-api-key:secret").Prefix(prefix).DocPrefix("proxy").StringSliceVar(fs, &config.Headers, nil, overrides)
+	flags.New("ProxyTimeout", "Maximum duration of a proxied request").Prefix(prefix).DocPrefix("proxy").DurationVar(fs, &config.Timeout, 30*time.Second, overrides)
+
+	return &config
+}
+
+type route struct {
+	handler http.Handler
+	prefix  string
+}
+
+// Service sends requests of configured path prefixes to their upstream, so that they never reach static files.
+type Service struct {
+	routes  []route
+	timeout time.Duration
+}
+
+// New creates the reverse proxies, requests being sent with the given transport to benefit from its instrumentation.
+func New(config *Config, transport http.RoundTripper) (*Service, error) {
+	if len(config.Routes) == 0 {
+		return nil, nil
+	}
+
+	headers := http.Header{}
+	for _, header := range config.Headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || len(name) == 0 || strings.Contains(name, " ") {
+			return nil, fmt.Errorf("invalid header `%s`, expecting name:value", header)
+		}
+
+		headers.Add(name, strings.TrimSpace(value))
+	}
+
+	service := &Service{
+		timeout: config.Timeout,
+	}
+
+	for _, value := range config.Routes {
+		prefix, upstream, ok := strings.Cut(value, "=")
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid route `%s`, expecting /prefix=http://upstream", value)
+		}
+
+		target, err := url.Parse(upstream)
+		if err != nil {
+			return nil, fmt.Errorf("parse upstream of `%s`: %w", prefix, err)
+		}
+
+		if target.Scheme != "http" && target.Scheme != "https" || len(target.Host) == 0 {
+			return nil, fmt.Errorf("invalid upstream `%s`, expecting an absolute http(s) URL", upstream)
+		}
+
+		prefix = strings.TrimSuffix(prefix, "/")
+		service.routes = append(service.routes, route{
+			prefix:  prefix,
+			handler: newReverseProxy(target, prefix, config.StripPrefix, headers, transport),
+		})
+	}
+
+	slices.SortFunc(service.routes, func(a, b route) int {
+		return len(b.prefix) - len(a.prefix)
+	})
+
+	return service, nil
+}
+
+func newReverseProxy(target *url.URL, prefix string, strip bool, headers http.Header, transport http.RoundTripper) http.Handler {
+	return &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(r *httputil.ProxyRequest) {
+			if strip {
+				r.Out.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.In.URL.Path, prefix), "/")
+				r.Out.URL.RawPath = ""
+			}
+
+			r.SetURL(target)
+			r.SetXForwarded()
+
+			for name, values := range headers {
+				r.Out.Header[name] = slices.Clone(values)
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			status := http.StatusBadGateway
+			if errors.Is(err, context.DeadlineExceeded) {
+				status = http.StatusGatewayTimeout
+			}
+
+			slog.LogAttrs(r.Context(), slog.LevelError, "proxy request", slog.String("upstream", target.Host), slog.String("path", r.URL.Path), slog.Any("error", err))
+
+			// httperror has no helper for gateway errors, headers mirror its own so that the failure is never cached
+			w.Header().Add("Cache-Control", "no-cache")
+			http.Error(w, http.StatusText(status), status)
+		},
+	}
+}
+
+func (r route) match(path string) bool {
+	return path == r.prefix || strings.HasPrefix(path, r.prefix+"/")
+}
+
+// Handles reports whether the path is sent to an upstream rather than served from files.
+func (s *Service) Handles(path string) bool {
+	if s == nil {
+		return false
+	}
+
+	return slices.ContainsFunc(s.routes, func(current route) bool {
+		return current.match(path)
+	})
+}
+
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index := slices.IndexFunc(s.routes, func(current route) bool {
+			return current.match(r.URL.Path)
+		})
+		if index == -1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if s.timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+			defer cancel()
+
+			r = r.WithContext(ctx)
+		}
+
+		s.routes[index].handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlags(t *testing.T) {
+	cases := map[string]struct {
+		want string
+	}{
+		"simple": {
+			"Usage of simple:\n  -proxy string slice\n    \t[proxy] Path prefix proxied to an upstream, as /prefix=http://upstream:8080 ${SIMPLE_PROXY}, as a string slice, environment variable separated by \",\"\n  -proxyHeader string slice\n    \t[proxy] Header set on requests sent to upstreams e.g. x-api-key:secret ${SIMPLE_PROXY_HEADER}, as a string slice, environment variable separated by \",\"\n  -proxyStripPrefix\n    \t[proxy] Remove the prefix from the path sent to the upstream ${SIMPLE_PROXY_STRIP_PREFIX} (default true)\n  -proxyTimeout duration\n    \t[proxy] Maximum duration of a proxied request ${SIMPLE_PROXY_TIMEOUT} (default 30s)\n",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			fs := flag.NewFlagSet(intention, flag.ContinueOnError)
+			Flags(fs, "")
+
+			var writer strings.Builder
+			fs.SetOutput(&writer)
+			fs.Usage()
+
+			result := writer.String()
+
+			if result != tc.want {
+				t.Errorf("Flags() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		config  Config
+		wantErr bool
+	}{
+		"disabled": {
+			Config{},
+			false,
+		},
+		"valid": {
+			Config{Routes: []string{"/api=http://localhost:8080", "/auth/=https://auth.example.com/v1"}, Headers: []string{"x-api-key: secret"}},
+			false,
+		},
+		"missing upstream": {
+			Config{Routes: []string{"/api"}},
+			true,
+		},
+		"relative upstream": {
+			Config{Routes: []string{"/api=localhost:8080"}},
+			true,
+		},
+		"invalid header": {
+			Config{Routes: []string{"/api=http://localhost:8080"}, Headers: []string{"x-api-key"}},
+			true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if _, err := New(&tc.config, nil); (err != nil) != tc.wantErr {
+				t.Errorf("New() = %s, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandles(t *testing.T) {
+	service, err := New(&Config{Routes: []string{"/api=http://localhost:8080", "/auth/=http://localhost:8081"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		service *Service
+		path    string
+		want    bool
+	}{
+		"disabled": {
+			nil,
+			"/api",
+			false,
+		},
+		"prefix": {
+			service,
+			"/api",
+			true,
+		},
+		"nested": {
+			service,
+			"/auth/login",
+			true,
+		},
+		"sibling": {
+			service,
+			"/apidoc/index.html",
+			false,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := tc.service.Handles(tc.path); got != tc.want {
+				t.Errorf("Handles() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		_, _ = fmt.Fprintf(w, "%s %s?%s key=%s forwarded=%s", r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("X-Api-Key"), r.Header.Get("X-Forwarded-Host"))
+	}))
+	defer upstream.Close()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "static")
+	})
+
+	cases := map[string]struct {
+		config     Config
+		method     string
+		path       string
+		wantStatus int
+		want       string
+	}{
+		"not proxied": {
+			Config{Routes: []string{"/api=" + upstream.URL}, StripPrefix: true},
+			http.MethodGet,
+			"/apidoc/index.html",
+			http.StatusOK,
+			"static",
+		},
+		"stripped": {
+			Config{Routes: []string{"/api/=" + upstream.URL}, StripPrefix: true, Headers: []string{"x-api-key:secret"}},
+			http.MethodPost,
+			"/api/users?page=2",
+			http.StatusOK,
+			"POST /users?page=2 key=secret forwarded=example.com",
+		},
+		"prefix root": {
+			Config{Routes: []string{"/api=" + upstream.URL}, StripPrefix: true},
+			http.MethodGet,
+			"/api",
+			http.StatusOK,
+			"GET /? key= forwarded=example.com",
+		},
+		"kept with upstream path": {
+			Config{Routes: []string{"/api=" + upstream.URL + "/v1"}},
+			http.MethodDelete,
+			"/api/users/1",
+			http.StatusOK,
+			"DELETE /v1/api/users/1? key= forwarded=example.com",
+		},
+		"timeout": {
+			Config{Routes: []string{"/api=" + upstream.URL}, StripPrefix: true, Timeout: 50 * time.Millisecond},
+			http.MethodGet,
+			"/api/slow",
+			http.StatusGatewayTimeout,
+			"Gateway Timeout\n",
+		},
+		"unreachable": {
+			Config{Routes: []string{"/api=http://127.0.0.1:1"}, StripPrefix: true},
+			http.MethodGet,
+			"/api/users",
+			http.StatusBadGateway,
+			"Bad Gateway\n",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			service, err := New(&tc.config, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writer := httptest.NewRecorder()
+			service.Middleware(next).ServeHTTP(writer, httptest.NewRequest(tc.method, tc.path, nil))
+
+			if result := writer.Code; result != tc.wantStatus {
+				t.Errorf("Middleware() = %d, want %d", result, tc.wantStatus)
+			}
+
+			if result := writer.Body.String(); result != tc.want {
+				t.Errorf("Middleware() = `%s`, want `%s`", result, tc.want)
+			}
+
+			if result := writer.Header().Get("Cache-Control"); tc.wantStatus >= http.StatusInternalServerError && result != "no-cache" {
+				t.Errorf("Cache-Control = `%s`, want `no-cache`", result)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package ratelimit
+
+import (
+	"container/list"
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViBiOh/flags"
+	"github.com/ViBiOh/viws/pkg/clientip"
+	"github.com/ViBiOh/viws/pkg/metrics"
+)
+
+var ipv6ClientMask = net.CIDRMask(64, 128)
+
+type Config struct {
+	Limits []string
+	Size   uint
+}
+
+func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
+	var config Config
+
+	flags.New("RateLimit", "Rate limits per client IP, as prefix:requests per second:burst, e.g. /:10:20").Prefix(prefix).DocPrefix("rateLimit").StringSliceVar(fs, &config.Limits, nil, overrides)
+	flags.New("RateLimitSize", "Maximum number of clients tracked, the least recently seen being forgotten first").Prefix(prefix).DocPrefix("rateLimit").UintVar(fs, &config.Size, 10000, overrides)
+
+	return &config
+}
+
+type limit struct {
+	throttled *metrics.Counter
+	prefix    string
+	rate      float64
+	burst     float64
+}
+
+// Service limits requests with a token bucket per client IP and path prefix, the longest matching prefix applying.
+type Service struct {
+	now      func() time.Time
+	buckets  map[string]*list.Element
+	lru      *list.List
+	tracked  *metrics.Gauge
+	limits   []limit
+	resolver clientip.Resolver
+	size     int
+	mutex    sync.Mutex
+}
+
+type bucket struct {
+	last   time.Time
+	key    string
+	tokens float64
+}
+
+func New(config *Config, resolver clientip.Resolver, registry *metrics.Registry) (*Service, error) {
+	if len(config.Limits) == 0 {
+		return nil, nil
+	}
+
+	service := &Service{
+		now:      time.Now,
+		buckets:  make(map[string]*list.Element),
+		lru:      list.New(),
+		resolver: resolver,
+		size:     max(int(config.Size), 1),
+		tracked:  registry.Gauge("viws_rate_limit_clients", "Clients tracked by rate limiter"),
+	}
+
+	for _, value := range config.Limits {
+		current, err := parseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+
+		current.throttled = registry.Counter("viws_rate_limited_total", "Requests rejected by rate limiter, by path prefix", "prefix", current.prefix)
+		service.limits = append(service.limits, current)
+	}
+
+	slices.SortFunc(service.limits, func(a, b limit) int {
+		return len(b.prefix) - len(a.prefix)
+	})
+
+	return service, nil
+}
+
+func parseLimit(value string) (limit, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "/") {
+		return limit{}, fmt.Errorf("invalid rate limit `%s`, expecting prefix:rate:burst", value)
+	}
+
+	rate, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || rate <= 0 {
+		return limit{}, fmt.Errorf("invalid rate of `%s`", value)
+	}
+
+	burst, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil || burst == 0 {
+		return limit{}, fmt.Errorf("invalid burst of `%s`", value)
+	}
+
+	return limit{prefix: parts[0], rate: rate, burst: float64(burst)}, nil
+}
+
+// clientKey identifies the client by its IPv4 address, or by the /64 network of its IPv6 address: a single host
+// usually owns a whole /64 and could otherwise get a fresh bucket per address.
+func clientKey(ip net.IP) string {
+	if ip == nil || ip.To4() != nil {
+		return ip.String()
+	}
+
+	return ip.Mask(ipv6ClientMask).String() + "/64"
+}
+
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index := slices.IndexFunc(s.limits, func(current limit) bool {
+			return strings.HasPrefix(r.URL.Path, current.prefix)
+		})
+		if index == -1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		current := s.limits[index]
+
+		ok, retryAfter := s.allow(fmt.Sprintf("%s|%s", clientKey(s.resolver.IP(r)), current.prefix), current)
+		if !ok {
+			current.throttled.Inc()
+
+			// httperror has no helper for 429, headers mirror its own so that the rejection is never cached
+			w.Header().Add("Cache-Control", "no-cache")
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow consumes a token of the client's bucket, it returns the number of seconds to wait when none is left.
+func (s *Service) allow(key string, current limit) (bool, int) {
+	now := s.now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var state *bucket
+
+	if element, ok := s.buckets[key]; ok {
+		s.lru.MoveToFront(element)
+		state = element.Value.(*bucket)
+
+		state.tokens = min(current.burst, state.tokens+now.Sub(state.last).Seconds()*current.rate)
+		state.last = now
+	} else {
+		if s.lru.Len() >= s.size {
+			oldest := s.lru.Back()
+			s.lru.Remove(oldest)
+			delete(s.buckets, oldest.Value.(*bucket).key)
+		}
+
+		state = &bucket{key: key, tokens: current.burst, last: now}
+		s.buckets[key] = s.lru.PushFront(state)
+		s.tracked.Set(int64(s.lru.Len()))
+	}
+
+	if state.tokens < 1 {
+		return false, int(math.Ceil((1 - state.tokens) / current.rate))
+	}
+
+	state.tokens--
+
+	return true, 0
+}
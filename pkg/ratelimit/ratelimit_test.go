@@ -0,0 +1,238 @@
+package ratelimit
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/viws/pkg/clientip"
+	"github.com/ViBiOh/viws/pkg/metrics"
+)
+
+func TestFlags(t *testing.T) {
+	cases := map[string]struct {
+		want string
+	}{
+		"simple": {
+			"Usage of simple:\n  -rateLimit string slice\n    \t[rateLimit] Rate limits per client IP, as prefix:requests per second:burst, e.g. /:10:20 ${SIMPLE_RATE_LIMIT}, as a string slice, environment variable separated by \",\"\n  -rateLimitSize uint\n    \t[rateLimit] Maximum number of clients tracked, the least recently seen being forgotten first ${SIMPLE_RATE_LIMIT_SIZE} (default 10000)\n",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			fs := flag.NewFlagSet(intention, flag.ContinueOnError)
+			Flags(fs, "")
+
+			var writer strings.Builder
+			fs.SetOutput(&writer)
+			fs.Usage()
+
+			result := writer.String()
+
+			if result != tc.want {
+				t.Errorf("Flags() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		limits  []string
+		wantErr bool
+	}{
+		"disabled": {
+			nil,
+			false,
+		},
+		"valid": {
+			[]string{"/:10:20", "/api/:0.5:1"},
+			false,
+		},
+		"missing burst": {
+			[]string{"/:10"},
+			true,
+		},
+		"invalid prefix": {
+			[]string{"api:10:20"},
+			true,
+		},
+		"invalid rate": {
+			[]string{"/:0:20"},
+			true,
+		},
+		"invalid burst": {
+			[]string{"/:10:0"},
+			true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if _, err := New(&Config{Limits: tc.limits, Size: 10}, clientip.Resolver{}, nil); (err != nil) != tc.wantErr {
+				t.Errorf("New() = %s, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	registry := metrics.New(&metrics.Config{Path: "/metrics"})
+
+	service, err := New(&Config{Limits: []string{"/:100:100", "/api/:1:2"}, Size: 3}, clientip.Resolver{}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	handler := service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	call := func(remote, path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.RemoteAddr = remote
+
+		writer := httptest.NewRecorder()
+		handler.ServeHTTP(writer, request)
+
+		return writer
+	}
+
+	for index, want := range []int{http.StatusNoContent, http.StatusNoContent, http.StatusTooManyRequests} {
+		if result := call("192.0.2.1:1234", "/api/users").Code; result != want {
+			t.Errorf("call #%d = %d, want %d", index, result, want)
+		}
+	}
+
+	rejected := call("192.0.2.1:1234", "/api/users")
+
+	if result := rejected.Header().Get("Retry-After"); result != "1" {
+		t.Errorf("Retry-After = `%s`, want `1`", result)
+	}
+
+	if result := rejected.Header().Get("Cache-Control"); result != "no-cache" {
+		t.Errorf("Cache-Control = `%s`, want `no-cache`", result)
+	}
+
+	if result := call("192.0.2.1:1234", "/index.html").Code; result != http.StatusNoContent {
+		t.Errorf("other prefix = %d, want %d", result, http.StatusNoContent)
+	}
+
+	if result := call("192.0.2.2:1234", "/api/users").Code; result != http.StatusNoContent {
+		t.Errorf("other client = %d, want %d", result, http.StatusNoContent)
+	}
+
+	now = now.Add(time.Second)
+
+	if result := call("192.0.2.1:1234", "/api/users").Code; result != http.StatusNoContent {
+		t.Errorf("after refill = %d, want %d", result, http.StatusNoContent)
+	}
+
+	if result := service.lru.Len(); result != 3 {
+		t.Errorf("tracked clients = %d, want 3", result)
+	}
+
+	call("192.0.2.3:1234", "/api/users")
+
+	if result := service.lru.Len(); result != 3 {
+		t.Errorf("tracked clients after eviction = %d, want 3", result)
+	}
+
+	var output strings.Builder
+	registry.Write(&output)
+
+	if want := `viws_rate_limited_total{prefix="/api/"} 2`; !strings.Contains(output.String(), want) {
+		t.Errorf("metrics don't contain `%s`:\n%s", want, output.String())
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	cases := map[string]struct {
+		ip   net.IP
+		want string
+	}{
+		"unknown": {
+			nil,
+			"<nil>",
+		},
+		"ipv4": {
+			net.ParseIP("192.0.2.1"),
+			"192.0.2.1",
+		},
+		"ipv4 mapped": {
+			net.ParseIP("::ffff:192.0.2.1"),
+			"192.0.2.1",
+		},
+		"ipv6": {
+			net.ParseIP("2001:db8:1:2:aaaa:bbbb:cccc:dddd"),
+			"2001:db8:1:2::/64",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := clientKey(tc.ip); got != tc.want {
+				t.Errorf("clientKey() = `%s`, want `%s`", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareIPv6(t *testing.T) {
+	service, err := New(&Config{Limits: []string{"/:1:1"}, Size: 10}, clientip.Resolver{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	handler := service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	cases := map[string]struct {
+		remote     string
+		wantStatus int
+	}{
+		"first address": {
+			"[2001:db8::1]:1234",
+			http.StatusNoContent,
+		},
+		"same network": {
+			"[2001:db8::2]:1234",
+			http.StatusTooManyRequests,
+		},
+		"other network": {
+			"[2001:db8:0:1::1]:1234",
+			http.StatusNoContent,
+		},
+	}
+
+	// Cases depend on each other, so they run in order
+	for _, intention := range []string{"first address", "same network", "other network"} {
+		tc := cases[intention]
+
+		t.Run(intention, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = tc.remote
+
+			writer := httptest.NewRecorder()
+			handler.ServeHTTP(writer, request)
+
+			if writer.Code != tc.wantStatus {
+				t.Errorf("Middleware() = %d, want %d", writer.Code, tc.wantStatus)
+			}
+		})
+	}
+
+	if result := service.lru.Len(); result != 2 {
+		t.Errorf("tracked clients = %d, want 2", result)
+	}
+}
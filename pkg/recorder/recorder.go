@@ -0,0 +1,80 @@
+// Package recorder observes the outcome of responses, for access logs and metrics.
+package recorder
+
+import (
+	"io"
+	"net/http"
+)
+
+// ResponseWriter records the final status and the body size, informational responses such as Early Hints being ignored.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func New(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= http.StatusOK {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *ResponseWriter) Write(content []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(content)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+// ReadFrom keeps the sendfile optimization of the underlying writer, used by http.ServeContent.
+func (w *ResponseWriter) ReadFrom(reader io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	var n int64
+	var err error
+
+	if readerFrom, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = readerFrom.ReadFrom(reader)
+	} else {
+		n, err = io.Copy(writerOnly{w.ResponseWriter}, reader)
+	}
+
+	w.bytes += n
+
+	return n, err
+}
+
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the final status, 200 when nothing was written.
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// Bytes returns the size of the body written.
+func (w *ResponseWriter) Bytes() int64 {
+	return w.bytes
+}
+
+// writerOnly hides optional interfaces, so that io.Copy doesn't loop on ReadFrom.
+type writerOnly struct {
+	io.Writer
+}
@@ -0,0 +1,61 @@
+package recorder
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// discardWriter accepts informational responses before the final one, unlike httptest.ResponseRecorder.
+type discardWriter struct {
+	header http.Header
+}
+
+func (w discardWriter) Header() http.Header             { return w.header }
+func (discardWriter) WriteHeader(int)                   {}
+func (discardWriter) Write(content []byte) (int, error) { return len(content), nil }
+
+func TestResponseWriter(t *testing.T) {
+	cases := map[string]struct {
+		write      func(w http.ResponseWriter)
+		wantStatus int
+		wantBytes  int64
+	}{
+		"nothing written": {
+			func(w http.ResponseWriter) {},
+			http.StatusOK,
+			0,
+		},
+		"early hints ignored": {
+			func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusEarlyHints)
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte("not found"))
+			},
+			http.StatusNotFound,
+			9,
+		},
+		"read from": {
+			func(w http.ResponseWriter) {
+				_, _ = w.(*ResponseWriter).ReadFrom(strings.NewReader("hello world"))
+			},
+			http.StatusOK,
+			11,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			writer := New(discardWriter{header: http.Header{}})
+			tc.write(writer)
+
+			if result := writer.Status(); result != tc.wantStatus {
+				t.Errorf("Status() = %d, want %d", result, tc.wantStatus)
+			}
+
+			if result := writer.Bytes(); result != tc.wantBytes {
+				t.Errorf("Bytes() = %d, want %d", result, tc.wantBytes)
+			}
+		})
+	}
+}
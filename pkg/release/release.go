@@ -0,0 +1,484 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ViBiOh/flags"
+)
+
+const (
+	Header = "X-Release"
+
+	releasesDirectory = "releases"
+	activeFilename    = "active"
+	historyFilename   = "history"
+	versionPath       = "/version"
+)
+
+var (
+	ErrNotFound      = errors.New("release not found")
+	ErrActive        = errors.New("release is active")
+	ErrExists        = errors.New("release already exists")
+	ErrNoPrevious    = errors.New("no previous release")
+	ErrInvalidID     = errors.New("invalid release id")
+	validReleaseName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+)
+
+type Release struct {
+	ModTime   time.Time `json:"modTime"`
+	ID        string    `json:"id"`
+	Directory string    `json:"-"`
+	Active    bool      `json:"active"`
+}
+
+type Config struct {
+	Directory string
+	Keep      uint
+	Poll      time.Duration
+}
+
+func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
+	var config Config
+
+	flags.New("Releases", "Directory of releases, serving releases/<id>/ named by the active file, instead of directory").Prefix(prefix).DocPrefix("release").StringVar(fs, &config.Directory, "", overrides)
+	flags.New("ReleasesKeep", "Number of releases to keep for rollback, 0 to keep all").Prefix(prefix).DocPrefix("release").UintVar(fs, &config.Keep, 5, overrides)
+	flags.New("ReleasesPoll", "Interval to check the active file for changes").Prefix(prefix).DocPrefix("release").DurationVar(fs, &config.Poll, 5*time.Second, overrides)
+
+	return &config
+}
+
+// Service tracks the active release. The active release is swapped atomically, a request reading it once is always
+// served from a single release.
+type Service struct {
+	active atomic.Pointer[Release]
+	mutex  sync.Mutex
+	root   string
+	keep   int
+	poll   time.Duration
+}
+
+func New(config *Config) (*Service, error) {
+	if len(config.Directory) == 0 {
+		return nil, nil
+	}
+
+	service := &Service{
+		root: config.Directory,
+		keep: int(config.Keep),
+		poll: config.Poll,
+	}
+
+	if err := os.MkdirAll(service.releasesDirectory(), 0o755); err != nil {
+		return nil, fmt.Errorf("create releases directory: %w", err)
+	}
+
+	if err := service.refresh(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read active release: %w", err)
+	}
+
+	if active := service.Active(); len(active.ID) != 0 {
+		slog.Info("Serving release", "id", active.ID, "dir", active.Directory)
+	} else {
+		slog.Warn("No active release", "dir", service.root)
+	}
+
+	return service, nil
+}
+
+func (s *Service) releasesDirectory() string {
+	return filepath.Join(s.root, releasesDirectory)
+}
+
+// Directory returns the directory of the given release, creating it from an empty one is up to the caller.
+func (s *Service) Directory(id string) (string, error) {
+	if !validReleaseName.MatchString(id) {
+		return "", ErrInvalidID
+	}
+
+	return filepath.Join(s.releasesDirectory(), id), nil
+}
+
+// Active returns the active release, with an empty ID if none has been activated yet.
+func (s *Service) Active() Release {
+	if active := s.active.Load(); active != nil {
+		return *active
+	}
+
+	return Release{}
+}
+
+// List returns known releases, from the most recent to the oldest. Releases are ordered by the history file, releases
+// missing from it, e.g. created before it existed, being older ones ordered by modification time.
+func (s *Service) List() ([]Release, error) {
+	entries, err := os.ReadDir(s.releasesDirectory())
+	if err != nil {
+		return nil, fmt.Errorf("read releases: %w", err)
+	}
+
+	history, err := s.readHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make(map[string]int, len(history))
+	for index, id := range history {
+		positions[id] = index + 1
+	}
+
+	active := s.Active()
+	output := make([]Release, 0, len(entries))
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !validReleaseName.MatchString(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("info of `%s`: %w", entry.Name(), err)
+		}
+
+		output = append(output, Release{
+			ID:        entry.Name(),
+			Directory: filepath.Join(s.releasesDirectory(), entry.Name()),
+			ModTime:   info.ModTime(),
+			Active:    entry.Name() == active.ID,
+		})
+	}
+
+	slices.SortStableFunc(output, func(a, b Release) int {
+		if compare := positions[b.ID] - positions[a.ID]; compare != 0 {
+			return compare
+		}
+
+		if compare := b.ModTime.Compare(a.ModTime); compare != 0 {
+			return compare
+		}
+
+		return strings.Compare(b.ID, a.ID)
+	})
+
+	return output, nil
+}
+
+func (s *Service) readHistory() ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(s.root, historyFilename))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+
+	return strings.Fields(string(content)), nil
+}
+
+// writeFile replaces the file of the root through a temporary file, so it's never seen half-written.
+func (s *Service) writeFile(name string, content []byte) error {
+	temporary := filepath.Join(s.root, "."+name+".tmp")
+	if err := os.WriteFile(temporary, content, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+
+	if err := os.Rename(temporary, filepath.Join(s.root, name)); err != nil {
+		return fmt.Errorf("rename %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// record appends the release to the history, the order in which releases are deployed, if it's not already in it.
+func (s *Service) record(id string) error {
+	history, err := s.readHistory()
+	if err != nil || slices.Contains(history, id) {
+		return err
+	}
+
+	return s.writeHistory(append(history, id))
+}
+
+func (s *Service) forget(id string) error {
+	history, err := s.readHistory()
+	if err != nil || !slices.Contains(history, id) {
+		return err
+	}
+
+	return s.writeHistory(slices.DeleteFunc(history, func(item string) bool { return item == id }))
+}
+
+func (s *Service) writeHistory(history []string) error {
+	var content strings.Builder
+
+	for _, id := range history {
+		content.WriteString(id)
+		content.WriteString("\n")
+	}
+
+	return s.writeFile(historyFilename, []byte(content.String()))
+}
+
+// Create fills a staging directory with the given function then moves it as the given release, so a release directory
+// is never seen partially written. The release is not activated.
+func (s *Service) Create(id string, fill func(directory string) error) error {
+	directory, err := s.Directory(id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(directory); err == nil {
+		return fmt.Errorf("%w: `%s`", ErrExists, id)
+	}
+
+	staging, err := os.MkdirTemp(s.releasesDirectory(), ".staging-")
+	if err != nil {
+		return fmt.Errorf("create staging: %w", err)
+	}
+
+	if err := fill(staging); err != nil {
+		return errors.Join(err, os.RemoveAll(staging))
+	}
+
+	if err := os.Chmod(staging, 0o755); err != nil {
+		return errors.Join(fmt.Errorf("chmod staging: %w", err), os.RemoveAll(staging))
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := os.Stat(directory); err == nil {
+		return errors.Join(fmt.Errorf("%w: `%s`", ErrExists, id), os.RemoveAll(staging))
+	}
+
+	if err := os.Rename(staging, directory); err != nil {
+		return errors.Join(fmt.Errorf("move staging: %w", err), os.RemoveAll(staging))
+	}
+
+	if err := s.record(id); err != nil {
+		return err
+	}
+
+	slog.Info("Release created", "id", id)
+
+	return nil
+}
+
+// Activate makes the given release the one served, then prunes the oldest ones.
+func (s *Service) Activate(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.activate(id, true)
+}
+
+// activate serves the given release, recording it in the history when deployed rather than rolled back to.
+func (s *Service) activate(id string, deployed bool) error {
+	directory, err := s.Directory(id)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+		return fmt.Errorf("%w: `%s`", ErrNotFound, id)
+	}
+
+	if deployed {
+		if err := s.record(id); err != nil {
+			return err
+		}
+	}
+
+	if err := s.writeFile(activeFilename, []byte(id+"\n")); err != nil {
+		return err
+	}
+
+	s.active.Store(&Release{ID: id, Directory: directory, Active: true})
+	slog.Info("Release activated", "id", id)
+
+	if err := s.prune(); err != nil {
+		slog.Error("prune releases", "error", err)
+	}
+
+	return nil
+}
+
+// Rollback activates the release deployed just before the active one, following the history rather than activations,
+// so that rolling back twice goes two releases back.
+func (s *Service) Rollback() (Release, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	releases, err := s.List()
+	if err != nil {
+		return Release{}, err
+	}
+
+	for index, release := range releases {
+		if release.Active {
+			if index+1 >= len(releases) {
+				break
+			}
+
+			previous := releases[index+1]
+			return previous, s.activate(previous.ID, false)
+		}
+	}
+
+	return Release{}, ErrNoPrevious
+}
+
+// Delete removes a release that is not active.
+func (s *Service) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.delete(id)
+}
+
+func (s *Service) delete(id string) error {
+	directory, err := s.Directory(id)
+	if err != nil {
+		return err
+	}
+
+	if id == s.Active().ID {
+		return ErrActive
+	}
+
+	if _, err := os.Stat(directory); err != nil {
+		return fmt.Errorf("%w: `%s`", ErrNotFound, id)
+	}
+
+	if err := os.RemoveAll(directory); err != nil {
+		return fmt.Errorf("remove: %w", err)
+	}
+
+	if err := s.forget(id); err != nil {
+		return err
+	}
+
+	slog.Info("Release deleted", "id", id)
+
+	return nil
+}
+
+func (s *Service) prune() error {
+	if s.keep <= 0 {
+		return nil
+	}
+
+	releases, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	if len(releases) <= s.keep {
+		return nil
+	}
+
+	var errs []error
+
+	for _, release := range releases[s.keep:] {
+		if !release.Active {
+			errs = append(errs, s.delete(release.ID))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// refresh reads the active file, in case it was changed by another process.
+func (s *Service) refresh() error {
+	content, err := os.ReadFile(filepath.Join(s.root, activeFilename))
+	if err != nil {
+		return err
+	}
+
+	id := strings.TrimSpace(string(content))
+	if id == s.Active().ID {
+		return nil
+	}
+
+	directory, err := s.Directory(id)
+	if err != nil {
+		return fmt.Errorf("`%s`: %w", id, err)
+	}
+
+	if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+		return fmt.Errorf("%w: `%s`", ErrNotFound, id)
+	}
+
+	// Releases activated by another process are deployed from now on
+	if err := s.record(id); err != nil {
+		return err
+	}
+
+	s.active.Store(&Release{ID: id, Directory: directory, Active: true})
+	slog.Info("Release activated", "id", id)
+
+	return nil
+}
+
+// Start watches the active file until context is done.
+func (s *Service) Start(ctx context.Context) {
+	if s == nil || s.poll <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			s.mutex.Lock()
+			err := s.refresh()
+			s.mutex.Unlock()
+
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.LogAttrs(ctx, slog.LevelError, "refresh active release", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// VersionMiddleware responds to `/version` with the active release.
+func (s *Service) VersionMiddleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != versionPath || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id := s.Active().ID
+
+		w.Header().Set(Header, id)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		if r.Method == http.MethodGet {
+			_, _ = fmt.Fprintln(w, id)
+		}
+	})
+}
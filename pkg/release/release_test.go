@@ -0,0 +1,221 @@
+package release
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlags(t *testing.T) {
+	cases := map[string]struct {
+		want string
+	}{
+		"simple": {
+			"Usage of simple:\n  -releases string\n    \t[release] Directory of releases, serving releases/<id>/ named by the active file, instead of directory ${SIMPLE_RELEASES}\n  -releasesKeep uint\n    \t[release] Number of releases to keep for rollback, 0 to keep all ${SIMPLE_RELEASES_KEEP} (default 5)\n  -releasesPoll duration\n    \t[release] Interval to check the active file for changes ${SIMPLE_RELEASES_POLL} (default 5s)\n",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			fs := flag.NewFlagSet(intention, flag.ContinueOnError)
+			Flags(fs, "")
+
+			var writer strings.Builder
+			fs.SetOutput(&writer)
+			fs.Usage()
+
+			result := writer.String()
+
+			if result != tc.want {
+				t.Errorf("Flags() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func createReleases(t *testing.T, root string, ids ...string) {
+	t.Helper()
+
+	now := time.Now()
+
+	for index, id := range ids {
+		directory := filepath.Join(root, releasesDirectory, id)
+
+		if err := os.MkdirAll(directory, 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(directory, "index.html"), []byte(id), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		modTime := now.Add(time.Duration(index-len(ids)) * time.Minute)
+		if err := os.Chtimes(directory, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if service, err := New(&Config{}); service != nil || err != nil {
+		t.Errorf("New() = (%v, %s), want (nil, nil)", service, err)
+	}
+
+	root := t.TempDir()
+	createReleases(t, root, "v1")
+
+	if err := os.WriteFile(filepath.Join(root, activeFilename), []byte("v1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := New(&Config{Directory: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := service.Active().ID; result != "v1" {
+		t.Errorf("Active() = `%s`, want `v1`", result)
+	}
+}
+
+func TestActivateAndRollback(t *testing.T) {
+	root := t.TempDir()
+	createReleases(t, root, "v1", "v2", "v3")
+
+	service, err := New(&Config{Directory: root, Keep: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Activate("../v1"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Activate() = %s, want %s", err, ErrInvalidID)
+	}
+
+	if err := service.Activate("v4"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Activate() = %s, want %s", err, ErrNotFound)
+	}
+
+	if err := service.Activate("v3"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(root, activeFilename))
+	if result := string(content); result != "v3\n" {
+		t.Errorf("active file = `%s`, want `v3`", result)
+	}
+
+	releases, _ := service.List()
+	if len(releases) != 2 || releases[0].ID != "v3" || !releases[0].Active || releases[1].ID != "v2" {
+		t.Errorf("List() = %+v, want v3 and v2 after prune", releases)
+	}
+
+	previous, err := service.Rollback()
+	if err != nil || previous.ID != "v2" || service.Active().ID != "v2" {
+		t.Errorf("Rollback() = (%+v, %s), want v2", previous, err)
+	}
+
+	if _, err := service.Rollback(); !errors.Is(err, ErrNoPrevious) {
+		t.Errorf("Rollback() = %s, want %s", err, ErrNoPrevious)
+	}
+
+	if err := service.Delete("v2"); !errors.Is(err, ErrActive) {
+		t.Errorf("Delete() = %s, want %s", err, ErrActive)
+	}
+
+	if err := service.Delete("v3"); err != nil {
+		t.Errorf("Delete() = %s", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	root := t.TempDir()
+
+	service, err := New(&Config{Directory: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"v1", "v2", "v3"} {
+		if err := service.Create(id, func(directory string) error {
+			return os.WriteFile(filepath.Join(directory, "index.html"), []byte(id), 0o600)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Modification time of directories doesn't follow the deploy order, e.g. after a copy
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(root, releasesDirectory, "v1"), now.Add(time.Hour), now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Activate("v3"); err != nil {
+		t.Fatal(err)
+	}
+
+	releases, err := service.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, release := range releases {
+		ids = append(ids, release.ID)
+	}
+
+	if want := []string{"v3", "v2", "v1"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("List() = %v, want %v", ids, want)
+	}
+
+	for _, want := range []string{"v2", "v1"} {
+		if previous, err := service.Rollback(); err != nil || previous.ID != want {
+			t.Errorf("Rollback() = (%+v, %s), want %s", previous, err, want)
+		}
+	}
+
+	if err := service.Delete("v3"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(root, historyFilename))
+	if result := string(content); result != "v1\nv2\n" {
+		t.Errorf("history file = `%s`, want `v1 v2`", result)
+	}
+}
+
+func TestVersionMiddleware(t *testing.T) {
+	root := t.TempDir()
+	createReleases(t, root, "v1")
+
+	service, err := New(&Config{Directory: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = service.Activate("v1")
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	writer := httptest.NewRecorder()
+	service.VersionMiddleware(next).ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if result := writer.Body.String(); result != "v1\n" {
+		t.Errorf("VersionMiddleware() = `%s`, want `v1`", result)
+	}
+
+	writer = httptest.NewRecorder()
+	service.VersionMiddleware(next).ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if result := writer.Code; result != http.StatusTeapot {
+		t.Errorf("VersionMiddleware() = %d, want %d", result, http.StatusTeapot)
+	}
+}
@@ -0,0 +1,367 @@
+package viws
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxGunzipSize bounds the uncompressed size of a .tar.gz archive, held in memory.
+const maxGunzipSize = 512 << 20
+
+// archiveStorage serves files from an index of archive entries built at startup, the archive being opened once.
+type archiveStorage struct {
+	content io.ReaderAt
+	closer  io.Closer
+	entries map[string]archiveEntry
+}
+
+type archiveEntry struct {
+	info    fs.FileInfo
+	zipFile *zip.File
+	offset  int64
+}
+
+type archiveFile struct {
+	*io.SectionReader
+	info fs.FileInfo
+}
+
+func (f archiveFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (archiveFile) Close() error {
+	return nil
+}
+
+// zipEntryFile streams a compressed zip entry, inflating it again from the start when seeking backward, so that
+// memory doesn't grow with the entry size.
+type zipEntryFile struct {
+	reader   io.ReadCloser
+	zipFile  *zip.File
+	info     fs.FileInfo
+	position int64
+	inflated int64
+}
+
+func (f *zipEntryFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *zipEntryFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.position
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return 0, errors.New("seek: invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("seek: negative position")
+	}
+
+	f.position = offset
+
+	return offset, nil
+}
+
+func (f *zipEntryFile) Read(content []byte) (int, error) {
+	if f.position >= f.info.Size() {
+		return 0, io.EOF
+	}
+
+	if f.reader == nil || f.position < f.inflated {
+		if err := f.Close(); err != nil {
+			return 0, err
+		}
+
+		reader, err := f.zipFile.Open()
+		if err != nil {
+			return 0, fmt.Errorf("inflate: %w", err)
+		}
+
+		f.reader = reader
+		f.inflated = 0
+	}
+
+	if skip := f.position - f.inflated; skip > 0 {
+		if _, err := io.CopyN(io.Discard, f.reader, skip); err != nil {
+			return 0, fmt.Errorf("skip: %w", err)
+		}
+
+		f.inflated += skip
+	}
+
+	read, err := f.reader.Read(content)
+	f.inflated += int64(read)
+	f.position = f.inflated
+
+	return read, err
+}
+
+func (f *zipEntryFile) Close() error {
+	if f.reader == nil {
+		return nil
+	}
+
+	err := f.reader.Close()
+	f.reader = nil
+
+	return err
+}
+
+type archiveDirInfo struct {
+	modTime time.Time
+	name    string
+}
+
+func (d archiveDirInfo) Name() string       { return d.name }
+func (archiveDirInfo) Size() int64          { return 0 }
+func (archiveDirInfo) Mode() fs.FileMode    { return fs.ModeDir | 0o555 }
+func (d archiveDirInfo) ModTime() time.Time { return d.modTime }
+func (archiveDirInfo) IsDir() bool          { return true }
+func (archiveDirInfo) Sys() any             { return nil }
+
+func openArchive(filename string) (*archiveStorage, error) {
+	reader, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+
+	info, err := reader.Stat()
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("stat: %w", err), reader.Close())
+	}
+
+	archive := &archiveStorage{
+		content: reader,
+		closer:  reader,
+		entries: make(map[string]archiveEntry),
+	}
+
+	switch name := strings.ToLower(filename); {
+	case strings.HasSuffix(name, ".zip"):
+		err = archive.indexZip(reader, info.Size())
+
+	case strings.HasSuffix(name, ".tar"):
+		err = archive.indexTar(reader)
+
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		// A gzip stream can't be read at an offset, so the tar is uncompressed in memory once, up to maxGunzipSize.
+		var content []byte
+
+		if content, err = gunzip(reader); err == nil {
+			buffer := bytes.NewReader(content)
+			archive.content = buffer
+			err = archive.indexTar(buffer)
+		}
+
+		err = errors.Join(err, reader.Close())
+		archive.closer = nil
+
+	default:
+		err = fmt.Errorf("unhandled archive format, expecting .zip, .tar or .tar.gz")
+	}
+
+	if err != nil {
+		return nil, errors.Join(err, archive.Close())
+	}
+
+	archive.addDirectories(info.ModTime())
+
+	return archive, nil
+}
+
+func gunzip(reader io.Reader) ([]byte, error) {
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(gzipReader, maxGunzipSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("gunzip: %w", err)
+	}
+
+	if len(content) > maxGunzipSize {
+		return nil, fmt.Errorf("gunzip: uncompressed archive exceeds %d bytes, use a .tar or a .zip", maxGunzipSize)
+	}
+
+	return content, gzipReader.Close()
+}
+
+func (a *archiveStorage) indexZip(reader io.ReaderAt, size int64) error {
+	zipReader, err := zip.NewReader(reader, size)
+	if err != nil {
+		return fmt.Errorf("zip: %w", err)
+	}
+
+	for _, zipFile := range zipReader.File {
+		if zipFile.FileInfo().IsDir() {
+			continue
+		}
+
+		entry := archiveEntry{
+			info:    zipFile.FileInfo(),
+			zipFile: zipFile,
+		}
+
+		// Stored entries are served directly from the archive, compressed ones are streamed on read.
+		if zipFile.Method == zip.Store {
+			if entry.offset, err = zipFile.DataOffset(); err != nil {
+				return fmt.Errorf("offset of `%s`: %w", zipFile.Name, err)
+			}
+
+			entry.zipFile = nil
+		}
+
+		a.add(zipFile.Name, entry)
+	}
+
+	return nil
+}
+
+func (a *archiveStorage) indexTar(reader io.ReadSeeker) error {
+	tarReader := tar.NewReader(reader)
+
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("tar: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		offset, err := reader.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("offset of `%s`: %w", header.Name, err)
+		}
+
+		a.add(header.Name, archiveEntry{
+			info:   header.FileInfo(),
+			offset: offset,
+		})
+	}
+}
+
+func (a *archiveStorage) add(name string, entry archiveEntry) {
+	if name = archiveName(name); name != "." {
+		a.entries[name] = entry
+	}
+}
+
+// addDirectories creates every parent directory of entries, archives not always containing directory entries.
+func (a *archiveStorage) addDirectories(modTime time.Time) {
+	a.entries["."] = archiveEntry{info: archiveDirInfo{name: ".", modTime: modTime}}
+
+	for name, entry := range a.entries {
+		if entry.info.IsDir() {
+			continue
+		}
+
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if _, ok := a.entries[dir]; ok {
+				break
+			}
+
+			a.entries[dir] = archiveEntry{info: archiveDirInfo{name: path.Base(dir), modTime: modTime}}
+		}
+	}
+}
+
+func archiveName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
+
+func (a *archiveStorage) lookup(name string) (archiveEntry, error) {
+	cleanName := archiveName(name)
+	if len(cleanName) == 0 {
+		cleanName = "."
+	}
+
+	entry, ok := a.entries[cleanName]
+	if !ok {
+		return entry, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return entry, nil
+}
+
+func (a *archiveStorage) Stat(name string) (fs.FileInfo, error) {
+	entry, err := a.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.info, nil
+}
+
+func (a *archiveStorage) Open(name string) (file, error) {
+	entry, err := a.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.info.IsDir() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+
+	if entry.zipFile == nil {
+		return archiveFile{SectionReader: io.NewSectionReader(a.content, entry.offset, entry.info.Size()), info: entry.info}, nil
+	}
+
+	return &zipEntryFile{zipFile: entry.zipFile, info: entry.info}, nil
+}
+
+// Walk calls fn for every regular file under root, by name order.
+func (a *archiveStorage) Walk(root string, fn func(name string, info fs.FileInfo) error) error {
+	prefix := archiveName(root)
+	if len(prefix) != 0 {
+		prefix += "/"
+	}
+
+	names := make([]string, 0, len(a.entries))
+	for name, entry := range a.entries {
+		if !entry.info.IsDir() && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		if err := fn(path.Join("/", name), a.entries[name].info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *archiveStorage) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+
+	return a.closer.Close()
+}
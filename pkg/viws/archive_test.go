@@ -0,0 +1,268 @@
+package viws
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	archiveModTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	archiveFiles   = map[string]string{
+		"index.html":         "<h1>Hello</h1>",
+		"assets/index.js":    "console.log('Ready');",
+		"./docs/readme.html": "<p>Docs</p>",
+	}
+)
+
+func writeZip(t *testing.T, filename string) {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+
+	for name, content := range archiveFiles {
+		method := zip.Deflate
+		if name == "index.html" {
+			method = zip.Store
+		}
+
+		entry, err := writer.CreateHeader(&zip.FileHeader{Name: filepath.Clean(name), Method: method, Modified: archiveModTime})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = entry.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filename, buffer.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTarGz(t *testing.T, filename string) {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	writer := tar.NewWriter(gzipWriter)
+
+	if err := writer.WriteHeader(&tar.Header{Name: "./docs/", Typeflag: tar.TypeDir, Mode: 0o755, ModTime: archiveModTime}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range archiveFiles {
+		if err := writer.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content)), ModTime: archiveModTime}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filename, buffer.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	directory := t.TempDir()
+
+	zipFile := filepath.Join(directory, "site.zip")
+	writeZip(t, zipFile)
+
+	tarFile := filepath.Join(directory, "site.tar.gz")
+	writeTarGz(t, tarFile)
+
+	cases := map[string]struct {
+		path        string
+		rangeHeader string
+		want        string
+		wantStatus  int
+	}{
+		"index": {
+			"/",
+			"",
+			"<h1>Hello</h1>",
+			http.StatusOK,
+		},
+		"asset": {
+			"/assets/index.js",
+			"",
+			"console.log('Ready');",
+			http.StatusOK,
+		},
+		"range": {
+			"/assets/index.js",
+			"bytes=0-6",
+			"console",
+			http.StatusPartialContent,
+		},
+		"nested": {
+			"/docs/readme.html",
+			"",
+			"<p>Docs</p>",
+			http.StatusOK,
+		},
+		"single page application": {
+			"/docs/",
+			"",
+			"<h1>Hello</h1>",
+			http.StatusOK,
+		},
+	}
+
+	for _, archive := range []string{zipFile, tarFile} {
+		instance, err := New(&Config{Archive: archive, Spa: true}, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for intention, tc := range cases {
+			t.Run(filepath.Base(archive)+" "+intention, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+				if len(tc.rangeHeader) != 0 {
+					req.Header.Set("Range", tc.rangeHeader)
+				}
+
+				writer := httptest.NewRecorder()
+				instance.Handler().ServeHTTP(writer, req)
+
+				if result := writer.Code; result != tc.wantStatus {
+					t.Errorf("Status %d, want %d", result, tc.wantStatus)
+				}
+
+				if result := writer.Body.String(); result != tc.want {
+					t.Errorf("Body `%s`, want `%s`", result, tc.want)
+				}
+
+				if result, want := writer.Header().Get("Last-Modified"), archiveModTime.Format(http.TimeFormat); result != want {
+					t.Errorf("Last-Modified `%s`, want `%s`", result, want)
+				}
+			})
+		}
+	}
+}
+
+func TestOpenArchive(t *testing.T) {
+	if _, err := openArchive("../../example/index.html"); err == nil {
+		t.Error("openArchive() = nil, want error on unhandled format")
+	}
+
+	if _, err := openArchive("nowhere.zip"); err == nil {
+		t.Error("openArchive() = nil, want error on missing archive")
+	}
+}
+
+func TestArchiveWalk(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "site.tar.gz")
+	writeTarGz(t, filename)
+
+	archive, err := openArchive(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		root string
+		want []string
+	}{
+		"all": {
+			"/",
+			[]string{"/assets/index.js", "/docs/readme.html", "/index.html"},
+		},
+		"sub directory": {
+			"/docs",
+			[]string{"/docs/readme.html"},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var got []string
+
+			if err := archive.Walk(tc.root, func(name string, _ fs.FileInfo) error {
+				got = append(got, name)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Walk() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestZipEntryFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "site.zip")
+	writeZip(t, filename)
+
+	archive, err := openArchive(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := archive.Open("/assets/index.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := reader.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	content := make([]byte, 7)
+
+	if _, err := reader.Seek(8, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.ReadFull(reader, content[:3]); err != nil {
+		t.Fatal(err)
+	}
+
+	if result, want := string(content[:3]), "log"; result != want {
+		t.Errorf("Read() after forward seek = `%s`, want `%s`", result, want)
+	}
+
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := io.ReadFull(reader, content); err != nil {
+		t.Fatal(err)
+	}
+
+	if result, want := string(content), "console"; result != want {
+		t.Errorf("Read() after backward seek = `%s`, want `%s`", result, want)
+	}
+}
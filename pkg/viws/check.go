@@ -0,0 +1,303 @@
+package viws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+var (
+	linkAttributeRegex = regexp.MustCompile(`(?i)\s(href|src|srcset)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+	sensitiveNames = map[string]bool{
+		".env":       true,
+		".git":       true,
+		".hg":        true,
+		".svn":       true,
+		".htpasswd":  true,
+		".npmrc":     true,
+		".ssh":       true,
+		".aws":       true,
+		"id_rsa":     true,
+		"id_ed25519": true,
+	}
+
+	sensitiveExtensions = map[string]bool{
+		".pem":  true,
+		".key":  true,
+		".p12":  true,
+		".pfx":  true,
+		".kdbx": true,
+	}
+)
+
+// Issue is a problem found in the served content, located by its URL path when it concerns a file.
+type Issue struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Path     string `json:"path,omitempty"`
+	Message  string `json:"message"`
+}
+
+type Report struct {
+	Directory string  `json:"directory"`
+	Issues    []Issue `json:"issues"`
+}
+
+func (r *Report) add(severity, code, path, format string, args ...any) {
+	r.Issues = append(r.Issues, Issue{
+		Severity: severity,
+		Code:     code,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Errors counts issues that would break the site once deployed.
+func (r Report) Errors() int {
+	var count int
+
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (r Report) Write(w io.Writer, asJSON bool) error {
+	if asJSON {
+		if r.Issues == nil {
+			r.Issues = []Issue{}
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(r)
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, issue := range r.Issues {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", issue.Severity, issue.Code, issue.Path, issue.Message)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	errorsCount := r.Errors()
+	_, err := fmt.Fprintf(w, "%s: %d error(s), %d warning(s)\n", r.Directory, errorsCount, len(r.Issues)-errorsCount)
+
+	return err
+}
+
+// Check lints the content that would be served with this configuration, resolving files the same way as the handler.
+// Links to endpoints, paths answered by the server itself such as /env, and links for which proxied returns true, sent
+// to an upstream, are not checked, both being optional.
+func Check(config *Config, endpoints []string, proxied func(string) bool) (Report, error) {
+	a, err := New(config, nil, nil, nil, nil)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Directory: config.Directory}
+	if len(config.Archive) != 0 {
+		report.Directory = config.Archive
+	}
+
+	for _, header := range config.Headers {
+		if _, _, ok := parseHeader(header); !ok {
+			report.add(SeverityError, "invalid-header", "", "header `%s` has wrong format, expecting name:value", header)
+		}
+	}
+
+	info, err := a.storage().Stat(a.directory)
+	if err != nil || !info.IsDir() {
+		report.add(SeverityError, "missing-directory", "", "`%s` is not a readable directory", a.directory)
+		return report, nil
+	}
+
+	if _, _, err := getFileToServe(a.storage(), a.directory, indexFilename); err != nil {
+		if a.spa {
+			report.add(SeverityError, "spa-without-index", "/", "Single Page Application mode without %s, every fallback will be a 404", indexFilename)
+		} else {
+			report.add(SeverityError, "missing-index", "/", "no %s, the root will be a 404", indexFilename)
+		}
+	}
+
+	if len(config.Archive) == 0 {
+		a.checkSymlinks(&report)
+	}
+
+	served := func(target string) bool {
+		return slices.Contains(endpoints, target) || proxied != nil && proxied(target)
+	}
+
+	exposed := make(map[string]bool)
+
+	err = a.storage().Walk(a.directory, func(name string, _ fs.FileInfo) error {
+		relative, err := filepath.Rel(a.directory, name)
+		if err != nil {
+			return err
+		}
+
+		relative = filepath.ToSlash(relative)
+
+		if exposure, severity := exposedPath(relative); len(exposure) != 0 {
+			if !exposed[exposure] {
+				exposed[exposure] = true
+				report.add(severity, "exposed-file", "/"+exposure, "file would be served publicly")
+			}
+
+			return nil
+		}
+
+		if isHTML(relative) {
+			a.checkLinks(&report, name, "/"+relative, served)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("walk: %w", err)
+	}
+
+	return report, nil
+}
+
+// exposedPath returns the first part of the path that is sensitive, as an error, or hidden, as a warning.
+func exposedPath(relative string) (string, string) {
+	parts := strings.Split(relative, "/")
+
+	for index, part := range parts {
+		prefix := strings.Join(parts[:index+1], "/")
+
+		if sensitiveNames[part] || strings.HasPrefix(part, ".env.") || sensitiveExtensions[strings.ToLower(path.Ext(part))] {
+			return prefix, SeverityError
+		}
+
+		if strings.HasPrefix(part, ".") && part != ".well-known" {
+			return prefix, SeverityWarning
+		}
+	}
+
+	return "", ""
+}
+
+// checkSymlinks reports symlinks of the directory that are dangling or that point outside of it, the handler following
+// them when serving.
+func (a App) checkSymlinks(report *Report) {
+	root, err := filepath.EvalSymlinks(a.directory)
+	if err != nil {
+		report.add(SeverityError, "missing-directory", "", "resolve `%s`: %s", a.directory, err)
+		return
+	}
+
+	_ = filepath.WalkDir(a.directory, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			report.add(SeverityError, "unreadable", name, "%s", err)
+			return nil
+		}
+
+		if entry.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		relative, _ := filepath.Rel(a.directory, name)
+		urlPath := "/" + filepath.ToSlash(relative)
+
+		target, err := filepath.EvalSymlinks(name)
+		if err != nil {
+			report.add(SeverityError, "dangling-symlink", urlPath, "symlink target is missing")
+			return nil
+		}
+
+		if inside, err := filepath.Rel(root, target); err != nil || inside == ".." || strings.HasPrefix(inside, ".."+string(os.PathSeparator)) {
+			report.add(SeverityError, "outside-root", urlPath, "symlink points outside of the directory, to `%s`", target)
+		}
+
+		return nil
+	})
+}
+
+// checkLinks reports internal links and assets references of an HTML page that can't be served.
+func (a App) checkLinks(report *Report, filename, page string, served func(string) bool) {
+	content, err := readFile(a.storage(), filename)
+	if err != nil {
+		report.add(SeverityError, "unreadable", page, "%s", err)
+		return
+	}
+
+	for _, match := range linkAttributeRegex.FindAllSubmatch(content, -1) {
+		value := string(match[2]) + string(match[3])
+
+		links := []string{value}
+		if strings.EqualFold(string(match[1]), "srcset") {
+			links = links[:0]
+
+			for candidate := range strings.SplitSeq(value, ",") {
+				if fields := strings.Fields(candidate); len(fields) != 0 {
+					links = append(links, fields[0])
+				}
+			}
+		}
+
+		for _, link := range links {
+			target, ok := resolveLink(page, link)
+			if !ok {
+				continue
+			}
+
+			if _, _, err := getFileToServe(a.storage(), a.directory, target); err == nil || a.seo.handles(target) {
+				continue
+			}
+
+			if served(target) {
+				continue
+			}
+
+			// Routes of a Single Page Application are served by the index
+			if a.spa && len(path.Ext(target)) == 0 {
+				continue
+			}
+
+			report.add(SeverityError, "broken-link", page, "`%s` not found", link)
+		}
+	}
+}
+
+// resolveLink returns the URL path targeted by a link of the page, when it's served by this site.
+func resolveLink(page, link string) (string, bool) {
+	link = strings.TrimSpace(link)
+	if len(link) == 0 || strings.HasPrefix(link, "#") {
+		return "", false
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || len(parsed.Scheme) != 0 || len(parsed.Host) != 0 || len(parsed.Path) == 0 {
+		return "", false
+	}
+
+	if strings.HasPrefix(parsed.Path, "/") {
+		return path.Clean(parsed.Path), true
+	}
+
+	return path.Join(path.Dir(page), parsed.Path), true
+}
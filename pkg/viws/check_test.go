@@ -0,0 +1,225 @@
+package viws
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	directory := t.TempDir()
+
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Join(directory, filepath.Dir(name)), 0o700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return directory
+}
+
+func issueCodes(report Report) []string {
+	var output []string
+
+	for _, issue := range report.Issues {
+		output = append(output, issue.Severity+":"+issue.Code+":"+issue.Path)
+	}
+
+	return output
+}
+
+func TestCheck(t *testing.T) {
+	cases := map[string]struct {
+		files   map[string]string
+		config  Config
+		want    []string
+		wantErr int
+	}{
+		"valid": {
+			map[string]string{
+				"index.html":               `<a href="/about/">About</a><a href="https://example.com">Out</a><a href="#top">Top</a><img src="img/logo.png" srcset="img/logo.png 1x, /img/logo.png 2x">`,
+				"about/index.html":         `<a href="../">Home</a><link rel="stylesheet" href="/app.css?v=1">`,
+				"app.css":                  "body{}",
+				"img/logo.png":             "",
+				".well-known/security.txt": "Contact: mailto:security@example.com",
+			},
+			Config{},
+			nil,
+			0,
+		},
+		"missing index": {
+			map[string]string{"app.css": "body{}"},
+			Config{},
+			[]string{"error:missing-index:/"},
+			1,
+		},
+		"spa without index": {
+			map[string]string{"app.css": "body{}"},
+			Config{Spa: true},
+			[]string{"error:spa-without-index:/"},
+			1,
+		},
+		"invalid header": {
+			map[string]string{"index.html": ""},
+			Config{Headers: []string{"content-language:fr", "x frame:deny", "no-value"}},
+			[]string{"error:invalid-header:", "error:invalid-header:"},
+			2,
+		},
+		"exposed files": {
+			map[string]string{
+				"index.html":     "",
+				".env":           "SECRET=1",
+				".git/HEAD":      "ref",
+				".git/config":    "",
+				"certs/site.pem": "",
+				".eslintrc":      "",
+			},
+			Config{},
+			[]string{"warning:exposed-file:/.eslintrc", "error:exposed-file:/.env", "error:exposed-file:/.git", "error:exposed-file:/certs/site.pem"},
+			3,
+		},
+		"broken links": {
+			map[string]string{
+				"index.html": `<a href="/about">About</a><script src='app.js'></script><img srcset="a.png 1x, b.png 2x">`,
+				"a.png":      "",
+			},
+			Config{},
+			[]string{"error:broken-link:/index.html", "error:broken-link:/index.html", "error:broken-link:/index.html"},
+			3,
+		},
+		"spa routes": {
+			map[string]string{"index.html": `<a href="/about">About</a><script src="app.js"></script>`},
+			Config{Spa: true},
+			[]string{"error:broken-link:/index.html"},
+			1,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			tc.config.Directory = writeSite(t, tc.files)
+
+			report, err := Check(&tc.config, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := issueCodes(report)
+			slices.Sort(got)
+			slices.Sort(tc.want)
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Check() = %v, want %v", got, tc.want)
+			}
+
+			if result := report.Errors(); result != tc.wantErr {
+				t.Errorf("Errors() = %d, want %d", result, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckSymlinks(t *testing.T) {
+	directory := writeSite(t, map[string]string{"index.html": "", "app.js": ""})
+	outside := writeSite(t, map[string]string{"secret.txt": ""})
+
+	links := map[string]string{
+		"linked.js": "app.js",
+		"dangling":  "nowhere",
+		"secret":    filepath.Join(outside, "secret.txt"),
+	}
+
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(directory, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := Check(&Config{Directory: directory}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"error:dangling-symlink:/dangling", "error:outside-root:/secret"}
+	if got := issueCodes(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() = %v, want %v", got, want)
+	}
+}
+
+func TestCheckProxied(t *testing.T) {
+	directory := writeSite(t, map[string]string{
+		"index.html": `<a href="/api/users">Users</a><a href="/apiary.html">Apiary</a>`,
+	})
+
+	report, err := Check(&Config{Directory: directory}, nil, func(path string) bool {
+		return path == "/api" || strings.HasPrefix(path, "/api/")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"error:broken-link:/index.html"}
+	if got := issueCodes(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() = %v, want %v", got, want)
+	}
+}
+
+func TestCheckEndpoints(t *testing.T) {
+	directory := writeSite(t, map[string]string{
+		"index.html": `<script src="/env"></script><a href="/metrics">Metrics</a><a href="/env/internal">Internal</a>`,
+	})
+
+	report, err := Check(&Config{Directory: directory}, []string{"/env", "/metrics"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"error:broken-link:/index.html"}
+	if got := issueCodes(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() = %v, want %v", got, want)
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	report := Report{
+		Directory: "/www",
+		Issues: []Issue{
+			{Severity: SeverityError, Code: "missing-index", Path: "/", Message: "no index.html"},
+			{Severity: SeverityWarning, Code: "exposed-file", Path: "/.eslintrc", Message: "file would be served publicly"},
+		},
+	}
+
+	var output bytes.Buffer
+	if err := report.Write(&output, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if result, want := output.String(), "/www: 1 error(s), 1 warning(s)\n"; !strings.HasSuffix(result, want) {
+		t.Errorf("Write() = `%s`, want suffix `%s`", result, want)
+	}
+
+	output.Reset()
+	if err := (Report{Directory: "/www"}).Write(&output, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(output.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if result, ok := decoded["issues"].([]any); !ok || len(result) != 0 {
+		t.Errorf("Write() issues = %v, want empty array", decoded["issues"])
+	}
+}
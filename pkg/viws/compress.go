@@ -0,0 +1,62 @@
+package viws
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+)
+
+// addEncodingVary adds `Vary: Accept-Encoding` when the file is compressed for clients accepting it, before
+// conditions are checked so that a 304 carries it too.
+func (a App) addEncodingVary(w http.ResponseWriter, filename string) {
+	if a.compress.Varies(mime.TypeByExtension(filepath.Ext(filename))) {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+}
+
+// serveCompressed serves the compressed variant of the file from cache, with range support. It returns false when
+// the file has to be served as is.
+func (a App) serveCompressed(w http.ResponseWriter, r *http.Request, filename, etag string, modTime time.Time, content file) bool {
+	encoding := a.compress.Negotiate(r.Header.Get("Accept-Encoding"))
+	if len(encoding) == 0 {
+		return false
+	}
+
+	info, err := content.Stat()
+	if err != nil {
+		return false
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if !a.compress.Cacheable(contentType, info.Size()) {
+		return false
+	}
+
+	compressed, err := a.compress.Compressed(filename, etag, encoding, func() ([]byte, error) {
+		return io.ReadAll(content)
+	})
+	if err != nil {
+		slog.LogAttrs(r.Context(), slog.LevelError, "compress file", slog.String("file", filename), slog.Any("error", err))
+
+		if _, err = content.Seek(0, io.SeekStart); err != nil {
+			httperror.InternalServerError(r.Context(), w, err)
+			return true
+		}
+
+		return false
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Encoding", encoding)
+
+	http.ServeContent(w, r, filename, modTime, bytes.NewReader(compressed))
+
+	return true
+}
@@ -0,0 +1,127 @@
+package viws
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ViBiOh/viws/pkg/compress"
+	"github.com/klauspost/compress/gzip"
+)
+
+func TestServeCompressed(t *testing.T) {
+	compressService, err := compress.New(&compress.Config{
+		Enabled:   true,
+		Encodings: []string{compress.Gzip},
+		Types:     []string{"text/css"},
+		GzipLevel: 6,
+		CacheSize: 1 << 20,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := New(&Config{Directory: exampleDir}, nil, nil, nil, compressService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original, err := os.ReadFile(exampleDir + "index.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		path           string
+		acceptEncoding string
+		rangeHeader    string
+		wantStatus     int
+		wantEncoding   string
+	}{
+		"compressed": {
+			"/index.css",
+			"gzip",
+			"",
+			http.StatusOK,
+			compress.Gzip,
+		},
+		"range of compressed": {
+			"/index.css",
+			"gzip",
+			"bytes=0-9",
+			http.StatusPartialContent,
+			compress.Gzip,
+		},
+		"not accepted": {
+			"/index.css",
+			"",
+			"",
+			http.StatusOK,
+			"",
+		},
+		"type not allowed": {
+			"/index.js",
+			"gzip",
+			"",
+			http.StatusOK,
+			"",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			request.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			if len(tc.rangeHeader) != 0 {
+				request.Header.Set("Range", tc.rangeHeader)
+			}
+
+			writer := httptest.NewRecorder()
+			instance.Handler().ServeHTTP(writer, request)
+
+			if writer.Code != tc.wantStatus {
+				t.Errorf("Handler() = %d, want %d", writer.Code, tc.wantStatus)
+			}
+
+			if got := writer.Header().Get("Content-Encoding"); got != tc.wantEncoding {
+				t.Errorf("Handler() Content-Encoding = `%s`, want `%s`", got, tc.wantEncoding)
+			}
+
+			if tc.wantEncoding != compress.Gzip {
+				return
+			}
+
+			if got := writer.Header().Get("Content-Type"); got != "text/css; charset=utf-8" {
+				t.Errorf("Handler() Content-Type = `%s`", got)
+			}
+
+			if got := writer.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Handler() Vary = `%s`", got)
+			}
+
+			if tc.wantStatus == http.StatusPartialContent {
+				if got := writer.Body.Len(); got != 10 {
+					t.Errorf("Handler() body length = %d, want 10", got)
+				}
+
+				return
+			}
+
+			reader, err := gzip.NewReader(writer.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			content, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(content) != string(original) {
+				t.Errorf("Handler() decoded = `%s`, want `%s`", content, original)
+			}
+		})
+	}
+}
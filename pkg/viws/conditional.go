@@ -0,0 +1,130 @@
+package viws
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func weakETag(hash string) string {
+	return fmt.Sprintf(`W/"%s"`, hash)
+}
+
+// checkConditions evaluates the conditional headers of the request in the order of RFC 9110, section 13.2.2, against
+// the validators of the representation. Etag, Cache-Control and Vary headers have to be set beforehand, so that a 304
+// carries them. It writes the 304 or 412 response and returns true when the request is done. If-Range is left to
+// http.ServeContent, given the same validators.
+func checkConditions(w http.ResponseWriter, r *http.Request, etag string, modTime time.Time) bool {
+	header := r.Header
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if value := header.Get("If-Match"); len(value) != 0 {
+		if !etagMatches(value, etag, true) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return true
+		}
+	} else if date, ok := conditionDate(header.Get("If-Unmodified-Since"), modTime); ok && modTime.Truncate(time.Second).After(date) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return true
+	}
+
+	if value := header.Get("If-None-Match"); len(value) != 0 {
+		if !etagMatches(value, etag, false) {
+			return false
+		}
+
+		if !readOnly {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return true
+		}
+
+		notModified(w)
+		return true
+	}
+
+	if date, ok := conditionDate(header.Get("If-Modified-Since"), modTime); ok && readOnly && !modTime.Truncate(time.Second).After(date) {
+		notModified(w)
+		return true
+	}
+
+	return false
+}
+
+// conditionDate parses the date of a header, ignored when the modification time is unknown.
+func conditionDate(value string, modTime time.Time) (time.Time, bool) {
+	if len(value) == 0 || modTime.IsZero() || modTime.Equal(time.Unix(0, 0)) {
+		return time.Time{}, false
+	}
+
+	date, err := http.ParseTime(value)
+
+	return date, err == nil
+}
+
+func notModified(w http.ResponseWriter) {
+	header := w.Header()
+
+	// Validators and caching headers are kept, so that the client refreshes its cached response
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// etagMatches checks if one of the entity-tags of the list matches, `*` matching any. The strong comparison never
+// matches a weak entity-tag, and files are only served with weak ones, hashed from their metadata and possibly
+// compressed: If-Match only succeeds with `*`.
+func etagMatches(list, etag string, strong bool) bool {
+	for {
+		list = strings.TrimLeft(list, " \t,")
+		if len(list) == 0 {
+			return false
+		}
+
+		if list[0] == '*' {
+			return true
+		}
+
+		candidate, remain := scanETag(list)
+		if len(candidate) == 0 {
+			return false
+		}
+
+		if strong {
+			if !isWeak(candidate) && !isWeak(etag) && candidate == etag {
+				return true
+			}
+		} else if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+
+		list = remain
+	}
+}
+
+func isWeak(etag string) bool {
+	return strings.HasPrefix(etag, "W/")
+}
+
+// scanETag reads the entity-tag at the beginning of the value, e.g. `W/"abc"`, and returns the remaining value.
+func scanETag(value string) (string, string) {
+	start := 0
+	if isWeak(value) {
+		start = 2
+	}
+
+	if len(value)-start < 2 || value[start] != '"' {
+		return "", ""
+	}
+
+	end := strings.IndexByte(value[start+1:], '"')
+	if end == -1 {
+		return "", ""
+	}
+
+	end += start + 2
+
+	return value[:end], value[end:]
+}
@@ -0,0 +1,292 @@
+package viws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ViBiOh/viws/pkg/compress"
+)
+
+func TestEtagMatches(t *testing.T) {
+	cases := map[string]struct {
+		list   string
+		etag   string
+		strong bool
+		want   bool
+	}{
+		"single": {
+			`W/"abc"`,
+			`W/"abc"`,
+			false,
+			true,
+		},
+		"list": {
+			`"xyz", W/"abc"`,
+			`W/"abc"`,
+			false,
+			true,
+		},
+		"comma in tag": {
+			`"a,b" ,W/"abc"`,
+			`W/"abc"`,
+			false,
+			true,
+		},
+		"weak comparison": {
+			`"abc"`,
+			`W/"abc"`,
+			false,
+			true,
+		},
+		"strong comparison of weak": {
+			`W/"abc"`,
+			`W/"abc"`,
+			true,
+			false,
+		},
+		"strong comparison": {
+			`"abc"`,
+			`"abc"`,
+			true,
+			true,
+		},
+		"star": {
+			`*`,
+			`W/"abc"`,
+			true,
+			true,
+		},
+		"none": {
+			`"xyz", W/"def"`,
+			`W/"abc"`,
+			false,
+			false,
+		},
+		"malformed": {
+			`abc`,
+			`W/"abc"`,
+			false,
+			false,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if got := etagMatches(tc.list, tc.etag, tc.strong); got != tc.want {
+				t.Errorf("etagMatches() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckConditions(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	etag := `W/"abc"`
+
+	before := modTime.Add(-time.Hour).Format(http.TimeFormat)
+	same := modTime.Format(http.TimeFormat)
+
+	cases := map[string]struct {
+		method     string
+		header     map[string]string
+		wantDone   bool
+		wantStatus int
+	}{
+		"unconditional": {
+			http.MethodGet,
+			nil,
+			false,
+			http.StatusOK,
+		},
+		"if-none-match": {
+			http.MethodGet,
+			map[string]string{"If-None-Match": `"xyz", W/"abc"`},
+			true,
+			http.StatusNotModified,
+		},
+		"if-none-match changed": {
+			http.MethodGet,
+			map[string]string{"If-None-Match": `W/"xyz"`, "If-Modified-Since": same},
+			false,
+			http.StatusOK,
+		},
+		"if-none-match not read only": {
+			http.MethodPost,
+			map[string]string{"If-None-Match": "*"},
+			true,
+			http.StatusPreconditionFailed,
+		},
+		"if-modified-since": {
+			http.MethodHead,
+			map[string]string{"If-Modified-Since": same},
+			true,
+			http.StatusNotModified,
+		},
+		"if-modified-since changed": {
+			http.MethodGet,
+			map[string]string{"If-Modified-Since": before},
+			false,
+			http.StatusOK,
+		},
+		"if-modified-since invalid": {
+			http.MethodGet,
+			map[string]string{"If-Modified-Since": "yesterday"},
+			false,
+			http.StatusOK,
+		},
+		"if-match weak": {
+			http.MethodGet,
+			map[string]string{"If-Match": etag},
+			true,
+			http.StatusPreconditionFailed,
+		},
+		"if-match star": {
+			http.MethodGet,
+			map[string]string{"If-Match": "*", "If-Unmodified-Since": before},
+			false,
+			http.StatusOK,
+		},
+		"if-unmodified-since": {
+			http.MethodGet,
+			map[string]string{"If-Unmodified-Since": same},
+			false,
+			http.StatusOK,
+		},
+		"if-unmodified-since changed": {
+			http.MethodGet,
+			map[string]string{"If-Unmodified-Since": before},
+			true,
+			http.StatusPreconditionFailed,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			request := httptest.NewRequest(tc.method, "/", nil)
+			for key, value := range tc.header {
+				request.Header.Set(key, value)
+			}
+
+			writer := httptest.NewRecorder()
+
+			if got := checkConditions(writer, request, etag, modTime); got != tc.wantDone {
+				t.Errorf("checkConditions() = %t, want %t", got, tc.wantDone)
+			}
+
+			if writer.Code != tc.wantStatus {
+				t.Errorf("Status %d, want %d", writer.Code, tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHandlerConditional(t *testing.T) {
+	compressService, err := compress.New(&compress.Config{
+		Enabled:   true,
+		Encodings: []string{compress.Gzip},
+		Types:     []string{"text/css"},
+		GzipLevel: 6,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := New(&Config{Directory: exampleDir}, nil, nil, nil, compressService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := httptest.NewRecorder()
+	instance.Handler().ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/index.css", nil))
+
+	etag := first.Header().Get("Etag")
+	lastModified := first.Header().Get("Last-Modified")
+
+	info, err := os.Stat(exampleDir + "index.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		header     map[string]string
+		wantStatus int
+		wantHeader http.Header
+	}{
+		"not modified": {
+			map[string]string{"If-None-Match": etag},
+			http.StatusNotModified,
+			http.Header{
+				"Etag":             {etag},
+				cacheControlHeader: {"public, max-age=864000"},
+				"Vary":             {"Accept-Encoding"},
+				"Content-Type":     {""},
+			},
+		},
+		"not modified since": {
+			map[string]string{"If-Modified-Since": lastModified},
+			http.StatusNotModified,
+			http.Header{
+				"Etag": {etag},
+			},
+		},
+		"precondition failed": {
+			map[string]string{"If-Match": `"other"`},
+			http.StatusPreconditionFailed,
+			nil,
+		},
+		"match emitted weak etag": {
+			map[string]string{"If-Match": etag},
+			http.StatusPreconditionFailed,
+			nil,
+		},
+		"match any": {
+			map[string]string{"If-Match": "*"},
+			http.StatusOK,
+			http.Header{
+				"Etag": {etag},
+			},
+		},
+		"range if unchanged": {
+			map[string]string{"Range": "bytes=0-4", "If-Range": lastModified},
+			http.StatusPartialContent,
+			http.Header{
+				"Content-Length": {"5"},
+			},
+		},
+		"range if changed": {
+			map[string]string{"Range": "bytes=0-4", "If-Range": info.ModTime().Add(-time.Hour).UTC().Format(http.TimeFormat)},
+			http.StatusOK,
+			nil,
+		},
+		"range if weak etag": {
+			map[string]string{"Range": "bytes=0-4", "If-Range": etag},
+			http.StatusOK,
+			nil,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/index.css", nil)
+			for key, value := range tc.header {
+				request.Header.Set(key, value)
+			}
+
+			writer := httptest.NewRecorder()
+			instance.Handler().ServeHTTP(writer, request)
+
+			if writer.Code != tc.wantStatus {
+				t.Errorf("Status %d, want %d", writer.Code, tc.wantStatus)
+			}
+
+			for key := range tc.wantHeader {
+				if got, want := writer.Header().Get(key), tc.wantHeader.Get(key); got != want {
+					t.Errorf("%s Header = `%s`, want `%s`", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package viws
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+	"github.com/ViBiOh/viws/pkg/dev"
+)
+
+const (
+	cspHeader        = "Content-Security-Policy"
+	scriptSrc        = "script-src"
+	styleSrc         = "style-src"
+	noncePlaceholder = "{{nonce}}"
+)
+
+var (
+	inlineTagRegex = regexp.MustCompile(`(?i)<(script|style)(\s[^>]*)?>`)
+	nonceAttrRegex = regexp.MustCompile(`(?i)\snonce\s*=`)
+)
+
+func isHTML(filename string) bool {
+	return strings.HasPrefix(mime.TypeByExtension(filepath.Ext(filename)), "text/html")
+}
+
+func generateNonce() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("read random: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+func injectNonce(content []byte, nonce string) []byte {
+	content = bytes.ReplaceAll(content, []byte(noncePlaceholder), []byte(nonce))
+
+	return inlineTagRegex.ReplaceAllFunc(content, func(tag []byte) []byte {
+		if nonceAttrRegex.Match(tag) {
+			return tag
+		}
+
+		name := inlineTagRegex.FindSubmatch(tag)[1]
+		output := make([]byte, 0, len(tag)+len(nonce)+10)
+
+		output = append(output, '<')
+		output = append(output, name...)
+		output = fmt.Appendf(output, ` nonce="%s"`, nonce)
+
+		return append(output, tag[len(name)+1:]...)
+	})
+}
+
+func addCSPSources(header http.Header, directive string, sources ...string) {
+	policy := header.Get(cspHeader)
+	if len(policy) == 0 || len(sources) == 0 {
+		return
+	}
+
+	header.Set(cspHeader, appendCSPSources(policy, directive, sources...))
+}
+
+// appendCSPSources adds sources to the given directive. When the directive is absent, it is created from the
+// default-src fallback so that adding a source doesn't restrict what was previously allowed.
+func appendCSPSources(policy, directive string, sources ...string) string {
+	var names []string
+	values := make(map[string][]string)
+
+	for directive := range strings.SplitSeq(policy, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+
+		name := strings.ToLower(fields[0])
+		if _, ok := values[name]; ok {
+			continue // only the first occurrence of a directive is enforced
+		}
+
+		names = append(names, name)
+		values[name] = fields[1:]
+	}
+
+	current, ok := values[directive]
+	if !ok {
+		defaultSrc, hasDefault := values["default-src"]
+		if !hasDefault {
+			return policy
+		}
+
+		names = append(names, directive)
+		current = append([]string(nil), defaultSrc...)
+	}
+
+	current = slices.DeleteFunc(current, func(source string) bool {
+		return strings.EqualFold(source, "'none'")
+	})
+
+	values[directive] = append(current, sources...)
+
+	directives := make([]string, 0, len(names))
+	for _, name := range names {
+		directives = append(directives, strings.Join(append([]string{name}, values[name]...), " "))
+	}
+
+	return strings.Join(directives, "; ")
+}
+
+// dynamicHTML indicates if HTML files are modified on each request.
+func (a App) dynamicHTML() bool {
+	return a.nonce || a.dev != nil
+}
+
+// serveDynamic serves an HTML file with a fresh nonce or the live reload script on each request, so the response is
+// never stored nor revalidated. It returns false without writing the response when the file doesn't exist anymore.
+func (a App) serveDynamic(w http.ResponseWriter, r *http.Request, status int, filename string) bool {
+	ctx := r.Context()
+
+	content, err := readFile(a.storage(), filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false
+		}
+
+		httperror.InternalServerError(ctx, w, err)
+		return true
+	}
+
+	if a.dev != nil {
+		if info, err := a.storage().Stat(filename); err == nil {
+			a.addInlineHashes(ctx, w, filename, info.ModTime())
+		}
+
+		content = dev.InjectScript(content)
+
+		// The nonce is added to the injected script tag too
+		if !a.nonce {
+			addCSPSources(w.Header(), scriptSrc, dev.ScriptHash())
+		}
+	}
+
+	if a.nonce {
+		nonce, err := generateNonce()
+		if err != nil {
+			httperror.InternalServerError(ctx, w, err)
+			return true
+		}
+
+		content = injectNonce(content, nonce)
+
+		nonceSource := fmt.Sprintf("'nonce-%s'", nonce)
+		addCSPSources(w.Header(), scriptSrc, nonceSource)
+		addCSPSources(w.Header(), styleSrc, nonceSource)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set(cacheControlHeader, noStoreValue)
+	w.WriteHeader(status)
+
+	if _, err = w.Write(content); err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "write dynamic content", slog.String("dir", a.directory), slog.Any("error", err))
+	}
+
+	return true
+}
@@ -0,0 +1,62 @@
+package viws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+var (
+	inlineScriptRegex = regexp.MustCompile(`(?is)<script(\s[^>]*)?>(.*?)</script\s*>`)
+	inlineStyleRegex  = regexp.MustCompile(`(?is)<style(\s[^>]*)?>(.*?)</style\s*>`)
+	srcAttrRegex      = regexp.MustCompile(`(?i)\ssrc\s*=`)
+)
+
+type inlineHashes struct {
+	scripts []string
+	styles  []string
+}
+
+func computeInlineHashes(content []byte) inlineHashes {
+	var output inlineHashes
+
+	for _, match := range inlineScriptRegex.FindAllSubmatch(content, -1) {
+		if len(match[2]) != 0 && !srcAttrRegex.Match(match[1]) {
+			output.scripts = append(output.scripts, cspHash(match[2]))
+		}
+	}
+
+	for _, match := range inlineStyleRegex.FindAllSubmatch(content, -1) {
+		if len(match[2]) != 0 {
+			output.styles = append(output.styles, cspHash(match[2]))
+		}
+	}
+
+	return output
+}
+
+func cspHash(content []byte) string {
+	sum := sha256.Sum256(content)
+
+	return fmt.Sprintf("'sha256-%s'", base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+func (a App) addInlineHashes(ctx context.Context, w http.ResponseWriter, filename string, modTime time.Time) {
+	if a.inlineHashes == nil || !isHTML(filename) {
+		return
+	}
+
+	hashes, err := a.inlineHashes.get(a.storage(), filename, modTime)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "compute inline hashes", slog.String("filename", filename), slog.Any("error", err))
+		return
+	}
+
+	addCSPSources(w.Header(), scriptSrc, hashes.scripts...)
+	addCSPSources(w.Header(), styleSrc, hashes.styles...)
+}
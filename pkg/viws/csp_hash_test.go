@@ -0,0 +1,59 @@
+package viws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestComputeInlineHashes(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		want  inlineHashes
+	}{
+		"empty": {
+			`<script src="/index.js"></script><style></style>`,
+			inlineHashes{},
+		},
+		"inline": {
+			"<script type=\"module\">run()</script>\n<STYLE>p{color:red}</STYLE>",
+			inlineHashes{
+				scripts: []string{"'sha256-AvyuiL0SD1mVY3NNxR+V2uo+lhk6RMFrytWmRt6CrJQ='"},
+				styles:  []string{"'sha256-p0bF+un5yUb9MBO6xRb8kPHlY2BdpHVtLiFkDrZPF64='"},
+			},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if result := computeInlineHashes([]byte(tc.input)); !reflect.DeepEqual(result, tc.want) {
+				t.Errorf("computeInlineHashes() = %+v, want %+v", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddInlineHashes(t *testing.T) {
+	directory := t.TempDir()
+	if err := os.WriteFile(filepath.Join(directory, indexFilename), []byte("<script>run()</script>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	instance := App{
+		directory:    directory,
+		inlineHashes: newFileCache(computeInlineHashes),
+	}
+
+	writer := httptest.NewRecorder()
+	writer.Header().Set(cspHeader, "default-src 'self'; style-src 'self'")
+
+	instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := "default-src 'self'; style-src 'self'; script-src 'self' 'sha256-AvyuiL0SD1mVY3NNxR+V2uo+lhk6RMFrytWmRt6CrJQ='"
+	if result := writer.Header().Get(cspHeader); result != want {
+		t.Errorf("%s = `%s`, want `%s`", cspHeader, result, want)
+	}
+}
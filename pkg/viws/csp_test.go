@@ -0,0 +1,156 @@
+package viws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/viws/pkg/dev"
+)
+
+func TestInjectNonce(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		want  string
+	}{
+		"no tag": {
+			"<p>Hello</p>",
+			"<p>Hello</p>",
+		},
+		"script and style": {
+			`<script>run()</script><STYLE media="print">p{}</STYLE><scripts>`,
+			`<script nonce="abc">run()</script><STYLE nonce="abc" media="print">p{}</STYLE><scripts>`,
+		},
+		"existing nonce": {
+			`<script nonce="other"></script>`,
+			`<script nonce="other"></script>`,
+		},
+		"placeholder": {
+			`<script nonce="{{nonce}}"></script><meta content="{{nonce}}">`,
+			`<script nonce="abc"></script><meta content="abc">`,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if result := string(injectNonce([]byte(tc.input), "abc")); result != tc.want {
+				t.Errorf("injectNonce() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestAppendCSPSources(t *testing.T) {
+	cases := map[string]struct {
+		policy string
+		want   string
+	}{
+		"default only": {
+			"default-src 'self'; base-uri 'self'",
+			"default-src 'self'; base-uri 'self'; script-src 'self' 'nonce-abc'; style-src 'self' 'nonce-abc'",
+		},
+		"existing directives": {
+			"default-src 'none'; script-src 'self' 'unsafe-inline';style-src https://cdn.example.com",
+			"default-src 'none'; script-src 'self' 'unsafe-inline' 'nonce-abc'; style-src https://cdn.example.com 'nonce-abc'",
+		},
+		"none directive": {
+			"default-src 'none'",
+			"default-src 'none'; script-src 'nonce-abc'; style-src 'nonce-abc'",
+		},
+		"no restriction": {
+			"frame-ancestors 'none'",
+			"frame-ancestors 'none'",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			result := appendCSPSources(appendCSPSources(tc.policy, scriptSrc, "'nonce-abc'"), styleSrc, "'nonce-abc'")
+			if result != tc.want {
+				t.Errorf("appendCSPSources() = `%s`, want `%s`", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestServeWithNonce(t *testing.T) {
+	instance := App{
+		directory: exampleDir,
+		nonce:     true,
+		spa:       true,
+	}
+
+	writer := httptest.NewRecorder()
+	writer.Header().Set(cspHeader, "default-src 'self'")
+
+	instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/user/1234", nil))
+
+	if result := writer.Code; result != http.StatusOK {
+		t.Errorf("Status %d, want %d", result, http.StatusOK)
+	}
+
+	if result := writer.Header().Get(cacheControlHeader); result != noStoreValue {
+		t.Errorf("Cache-Control = `%s`, want `%s`", result, noStoreValue)
+	}
+
+	if result := writer.Header().Get("Etag"); len(result) != 0 {
+		t.Errorf("Etag = `%s`, want none", result)
+	}
+
+	matches := regexp.MustCompile(`script-src 'self' 'nonce-([^']+)'`).FindStringSubmatch(writer.Header().Get(cspHeader))
+	if len(matches) != 2 {
+		t.Fatalf("%s = `%s`, want a nonce", cspHeader, writer.Header().Get(cspHeader))
+	}
+
+	if want := `<script nonce="` + matches[1] + `" src="/index.js">`; !strings.Contains(writer.Body.String(), want) {
+		t.Errorf("Body `%s`, want `%s`", writer.Body.String(), want)
+	}
+}
+
+func TestServeDevCSP(t *testing.T) {
+	devService, err := dev.New(&dev.Config{Enabled: true}, exampleDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		nonce    bool
+		wantHash bool
+	}{
+		"default": {
+			false,
+			true,
+		},
+		"nonce": {
+			true,
+			false,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			instance := App{
+				directory: exampleDir,
+				dev:       devService,
+				nonce:     tc.nonce,
+			}
+
+			writer := httptest.NewRecorder()
+			writer.Header().Set(cspHeader, "default-src 'self'; base-uri 'self'")
+
+			instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			policy := writer.Header().Get(cspHeader)
+
+			if result := strings.Contains(policy, "script-src 'self' "+dev.ScriptHash()); result != tc.wantHash {
+				t.Errorf("%s = `%s`, want script hash %t", cspHeader, policy, tc.wantHash)
+			}
+
+			if result := strings.Contains(policy, "'nonce-"); result != tc.nonce {
+				t.Errorf("%s = `%s`, want nonce %t", cspHeader, policy, tc.nonce)
+			}
+		})
+	}
+}
@@ -1,14 +1,73 @@
 package viws
 
 import (
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 )
 
-func getFileToServe(parts ...string) (string, os.FileInfo, error) {
+type file interface {
+	fs.File
+	io.Seeker
+}
+
+// storage is where served files are read from, filenames being the joined served directory and request path.
+type storage interface {
+	Stat(name string) (fs.FileInfo, error)
+	Open(name string) (file, error)
+	Walk(root string, fn func(name string, info fs.FileInfo) error) error
+}
+
+type osStorage struct{}
+
+func (osStorage) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osStorage) Open(name string) (file, error) {
+	return os.OpenFile(name, os.O_RDONLY, 0o600)
+}
+
+// Walk calls fn for every regular file under root.
+func (osStorage) Walk(root string, fn func(name string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		return fn(name, info)
+	})
+}
+
+func (a App) storage() storage {
+	if a.files == nil {
+		return osStorage{}
+	}
+
+	return a.files
+}
+
+func readFile(files storage, name string) ([]byte, error) {
+	reader, err := files.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	return io.ReadAll(reader)
+}
+
+func getFileToServe(files storage, parts ...string) (string, os.FileInfo, error) {
 	filename := filepath.Join(parts...)
 
-	info, err := os.Stat(filename)
+	info, err := files.Stat(filename)
 	if err != nil {
 		return "", nil, err
 	}
@@ -17,5 +76,5 @@ func getFileToServe(parts ...string) (string, os.FileInfo, error) {
 		return filename, info, nil
 	}
 
-	return getFileToServe(filename, indexFilename)
+	return getFileToServe(files, filename, indexFilename)
 }
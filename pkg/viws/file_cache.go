@@ -0,0 +1,83 @@
+package viws
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// fileCacheSize is the number of files a fileCache holds, the least recently used being evicted first, so files of
+// previous releases or previews don't stay in memory for good.
+const fileCacheSize = 1024
+
+type cachedFile[T any] struct {
+	filename string
+	modTime  time.Time
+	value    T
+}
+
+// fileCache holds a value computed from a file content, recomputed when the file modification time changes.
+type fileCache[T any] struct {
+	files   map[string]*list.Element
+	lru     *list.List
+	compute func([]byte) T
+	size    int
+	mutex   sync.Mutex
+}
+
+func newFileCache[T any](compute func([]byte) T) *fileCache[T] {
+	return &fileCache[T]{
+		files:   make(map[string]*list.Element),
+		lru:     list.New(),
+		compute: compute,
+		size:    fileCacheSize,
+	}
+}
+
+func (c *fileCache[T]) get(files storage, filename string, modTime time.Time) (T, error) {
+	c.mutex.Lock()
+	if element, ok := c.files[filename]; ok {
+		if cached := element.Value.(*cachedFile[T]); cached.modTime.Equal(modTime) {
+			c.lru.MoveToFront(element)
+			c.mutex.Unlock()
+
+			return cached.value, nil
+		}
+	}
+	c.mutex.Unlock()
+
+	content, err := readFile(files, filename)
+	if err != nil {
+		var output T
+		return output, fmt.Errorf("read: %w", err)
+	}
+
+	cached := &cachedFile[T]{
+		filename: filename,
+		modTime:  modTime,
+		value:    c.compute(content),
+	}
+
+	c.mutex.Lock()
+	c.add(cached)
+	c.mutex.Unlock()
+
+	return cached.value, nil
+}
+
+// add stores the value, replacing the one of a previous modification time, lock being held.
+func (c *fileCache[T]) add(cached *cachedFile[T]) {
+	if element, ok := c.files[cached.filename]; ok {
+		c.lru.Remove(element)
+		delete(c.files, cached.filename)
+	}
+
+	for c.lru.Len() >= c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.files, oldest.Value.(*cachedFile[T]).filename)
+	}
+
+	c.files[cached.filename] = c.lru.PushFront(cached)
+}
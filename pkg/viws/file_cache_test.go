@@ -0,0 +1,86 @@
+package viws
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCache(t *testing.T) {
+	directory := writeSite(t, map[string]string{
+		"first.html":  "first",
+		"second.html": "second",
+		"third.html":  "third",
+	})
+
+	first := filepath.Join(directory, "first.html")
+	second := filepath.Join(directory, "second.html")
+	third := filepath.Join(directory, "third.html")
+
+	now := time.Now()
+	later := now.Add(time.Minute)
+
+	cases := map[string]struct {
+		gets         []string
+		modTimes     []time.Time
+		wantComputes int
+		wantLen      int
+	}{
+		"hit": {
+			[]string{first, first},
+			[]time.Time{now, now},
+			1,
+			1,
+		},
+		"modified": {
+			[]string{first, first},
+			[]time.Time{now, later},
+			2,
+			1,
+		},
+		"evicted": {
+			[]string{first, second, third, first},
+			[]time.Time{now, now, now, now},
+			4,
+			2,
+		},
+		"recently used": {
+			[]string{first, second, first, third, first},
+			[]time.Time{now, now, now, now, now},
+			3,
+			2,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			var computes int
+
+			cache := newFileCache(func(content []byte) string {
+				computes++
+
+				return string(content)
+			})
+			cache.size = 2
+
+			for index, filename := range tc.gets {
+				got, err := cache.get(osStorage{}, filename, tc.modTimes[index])
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if want := filepath.Base(filename); got+".html" != want {
+					t.Errorf("get(`%s`) = `%s`, want `%s`", filename, got, want)
+				}
+			}
+
+			if computes != tc.wantComputes {
+				t.Errorf("computes = %d, want %d", computes, tc.wantComputes)
+			}
+
+			if got := len(cache.files); got != tc.wantLen || cache.lru.Len() != tc.wantLen {
+				t.Errorf("len = %d, want %d", got, tc.wantLen)
+			}
+		})
+	}
+}
@@ -39,7 +39,7 @@ func TestGetFileToServe(t *testing.T) {
 
 	for intention, tc := range cases {
 		t.Run(intention, func(t *testing.T) {
-			result, _, err := getFileToServe(tc.args.directory, tc.args.path)
+			result, _, err := getFileToServe(osStorage{}, tc.args.directory, tc.args.path)
 
 			failed := false
 
@@ -0,0 +1,74 @@
+package viws
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// imageVariants are the modern formats served instead of an image when accepted, by order of preference.
+var imageVariants = []struct {
+	extension string
+	mediaType string
+}{
+	{".avif", "image/avif"},
+	{".webp", "image/webp"},
+}
+
+func isVariableImage(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	default:
+		return false
+	}
+}
+
+// acceptedMediaTypes returns media types explicitly accepted, wildcards being ignored: a browser sending `image/*`
+// doesn't necessarily decode every format.
+func acceptedMediaTypes(accept string) map[string]bool {
+	output := make(map[string]bool)
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+
+		accepted := true
+		for _, param := range strings.Split(params, ";") {
+			if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(key) == "q" {
+				quality, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				accepted = err == nil && quality > 0
+			}
+		}
+
+		output[strings.ToLower(strings.TrimSpace(mediaType))] = accepted
+	}
+
+	return output
+}
+
+// imageVariant returns the AVIF or WebP sibling of a JPEG, PNG or GIF image if the client accepts it, the image
+// itself otherwise. The response varies on Accept as soon as the image is a candidate.
+func (a App) imageVariant(w http.ResponseWriter, r *http.Request, filename string, info os.FileInfo) (string, os.FileInfo) {
+	if !a.imageVariants || !isVariableImage(filename) {
+		return filename, info
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	accepted := acceptedMediaTypes(r.Header.Get("Accept"))
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+
+	for _, variant := range imageVariants {
+		if !accepted[variant.mediaType] {
+			continue
+		}
+
+		if variantInfo, err := a.storage().Stat(base + variant.extension); err == nil && !variantInfo.IsDir() {
+			return base + variant.extension, variantInfo
+		}
+	}
+
+	return filename, info
+}
@@ -0,0 +1,117 @@
+package viws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImageVariant(t *testing.T) {
+	directory := t.TempDir()
+
+	for _, name := range []string{"photo.jpg", "photo.webp", "photo.avif", "logo.png", "logo.webp"} {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	enabled, err := New(&Config{Directory: directory, ImageVariants: true}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disabled, err := New(&Config{Directory: directory}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		instance App
+		path     string
+		accept   string
+		want     string
+		wantType string
+		wantVary string
+	}{
+		"avif preferred": {
+			enabled,
+			"/photo.jpg",
+			"image/webp,image/avif,image/*;q=0.8",
+			"photo.avif",
+			"image/avif",
+			"Accept",
+		},
+		"avif refused": {
+			enabled,
+			"/photo.jpg",
+			"image/avif;q=0,image/webp",
+			"photo.webp",
+			"image/webp",
+			"Accept",
+		},
+		"missing sibling": {
+			enabled,
+			"/logo.png",
+			"image/avif,image/webp",
+			"logo.webp",
+			"image/webp",
+			"Accept",
+		},
+		"wildcard": {
+			enabled,
+			"/photo.jpg",
+			"image/*,*/*;q=0.8",
+			"photo.jpg",
+			"image/jpeg",
+			"Accept",
+		},
+		"not an image": {
+			enabled,
+			"/photo.webp",
+			"image/avif",
+			"photo.webp",
+			"image/webp",
+			"",
+		},
+		"disabled": {
+			disabled,
+			"/photo.jpg",
+			"image/avif,image/webp",
+			"photo.jpg",
+			"image/jpeg",
+			"",
+		},
+	}
+
+	etags := make(map[string]string)
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			request.Header.Set("Accept", tc.accept)
+
+			writer := httptest.NewRecorder()
+			tc.instance.Handler().ServeHTTP(writer, request)
+
+			if got := writer.Body.String(); got != tc.want {
+				t.Errorf("Handler() = `%s`, want `%s`", got, tc.want)
+			}
+
+			if got := writer.Header().Get("Content-Type"); got != tc.wantType {
+				t.Errorf("Handler() Content-Type = `%s`, want `%s`", got, tc.wantType)
+			}
+
+			if got := writer.Header().Get("Vary"); got != tc.wantVary {
+				t.Errorf("Handler() Vary = `%s`, want `%s`", got, tc.wantVary)
+			}
+
+			etags[intention] = writer.Header().Get("Etag")
+		})
+	}
+
+	if etags["avif preferred"] == etags["avif refused"] || etags["avif refused"] == etags["wildcard"] {
+		t.Errorf("Handler() served variants with a same Etag: %v", etags)
+	}
+}
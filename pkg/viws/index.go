@@ -0,0 +1,262 @@
+package viws
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/hash"
+)
+
+// indexedFile is the metadata of a file kept in the index, with its version computed once.
+type indexedFile struct {
+	modTime time.Time
+	name    string
+	hash    string
+	size    int64
+	mode    fs.FileMode
+}
+
+func newIndexedFile(info fs.FileInfo) indexedFile {
+	output := indexedFile{
+		name:    info.Name(),
+		size:    info.Size(),
+		mode:    info.Mode(),
+		modTime: info.ModTime(),
+	}
+
+	if !info.IsDir() {
+		output.hash = hash.Hash(info)
+	}
+
+	return output
+}
+
+func (f indexedFile) Name() string       { return f.name }
+func (f indexedFile) Size() int64        { return f.size }
+func (f indexedFile) Mode() fs.FileMode  { return f.mode }
+func (f indexedFile) ModTime() time.Time { return f.modTime }
+func (f indexedFile) IsDir() bool        { return f.mode.IsDir() }
+func (f indexedFile) Sys() any           { return nil }
+
+// fileHash returns the version of a file, already computed when it comes from the index.
+func fileHash(info fs.FileInfo) string {
+	if indexed, ok := info.(indexedFile); ok {
+		return indexed.hash
+	}
+
+	return hash.Hash(info)
+}
+
+type indexSnapshot struct {
+	files   map[string]indexedFile
+	linked  map[string]bool
+	root    string
+	regular []string
+}
+
+func (s *indexSnapshot) contains(name string) bool {
+	return name == s.root || strings.HasPrefix(name, strings.TrimSuffix(s.root, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// throughLink checks if the name is under a symlinked directory, whose content isn't indexed.
+func (s *indexSnapshot) throughLink(name string) bool {
+	for dir := filepath.Dir(name); dir != s.root && s.contains(dir); dir = filepath.Dir(dir) {
+		if s.linked[dir] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fileIndex resolves files of the served directory from memory, so that a request costs no syscall until the file is
+// opened. It's rebuilt off the request path, names outside of the indexed directory being read from disk.
+type fileIndex struct {
+	storage  storage
+	snapshot atomic.Pointer[indexSnapshot]
+	refresh  time.Duration
+}
+
+func newFileIndex(config *Config) *fileIndex {
+	if !config.Index || len(config.Archive) != 0 {
+		return nil
+	}
+
+	return &fileIndex{
+		storage: osStorage{},
+		refresh: config.IndexRefresh,
+	}
+}
+
+func (i *fileIndex) Stat(name string) (fs.FileInfo, error) {
+	snapshot := i.snapshot.Load()
+	if snapshot == nil || !snapshot.contains(name) {
+		return i.storage.Stat(name)
+	}
+
+	if info, ok := snapshot.files[name]; ok {
+		return info, nil
+	}
+
+	if snapshot.throughLink(name) {
+		return i.storage.Stat(name)
+	}
+
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// Open reads the file from disk, a file deleted since the index was built failing with fs.ErrNotExist.
+func (i *fileIndex) Open(name string) (file, error) {
+	return i.storage.Open(name)
+}
+
+// Walk calls fn for every regular file under root, from the index when it covers it.
+func (i *fileIndex) Walk(root string, fn func(name string, info fs.FileInfo) error) error {
+	snapshot := i.snapshot.Load()
+	if snapshot == nil || !snapshot.contains(root) {
+		return i.storage.Walk(root, fn)
+	}
+
+	prefix := strings.TrimSuffix(root, string(filepath.Separator)) + string(filepath.Separator)
+
+	for _, name := range snapshot.regular {
+		if strings.HasPrefix(name, prefix) {
+			if err := fn(name, snapshot.files[name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// build walks the directory, following symlinks of files as they are served. Content of symlinked directories is
+// read from disk.
+func (i *fileIndex) build(root string) (*indexSnapshot, error) {
+	snapshot := &indexSnapshot{
+		root:   filepath.Clean(root),
+		files:  make(map[string]indexedFile),
+		linked: make(map[string]bool),
+	}
+
+	// The trailing separator follows the root if it's a symlink
+	err := filepath.WalkDir(snapshot.root+string(filepath.Separator), func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name = filepath.Clean(name)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if info, err = os.Stat(name); err != nil {
+				// Dangling symlinks are not served
+				return nil
+			}
+
+			if info.IsDir() {
+				snapshot.linked[name] = true
+			}
+		} else if entry.Type().IsRegular() {
+			snapshot.regular = append(snapshot.regular, name)
+		}
+
+		snapshot.files[name] = newIndexedFile(info)
+
+		return nil
+	})
+
+	return snapshot, err
+}
+
+func (i *fileIndex) update(ctx context.Context, root string) {
+	start := time.Now()
+
+	snapshot, err := i.build(root)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "index files, keeping previous index", slog.String("dir", root), slog.Any("error", err))
+		return
+	}
+
+	i.snapshot.Store(snapshot)
+	slog.LogAttrs(ctx, slog.LevelDebug, "Files indexed", slog.String("dir", root), slog.Int("count", len(snapshot.files)), slog.Duration("duration", time.Since(start)))
+}
+
+// indexRoot is the directory to index, following the active release.
+func (a App) indexRoot() string {
+	if a.releases != nil {
+		return a.releases.Active().Directory
+	}
+
+	return a.directory
+}
+
+// Start rebuilds the file index and the sitemap at their refresh interval, and both on SIGHUP, e.g. sent once a
+// deploy is synced, until context is done.
+func (a App) Start(ctx context.Context) {
+	if a.index == nil && a.seo == nil {
+		return
+	}
+
+	var indexTick, seoTick <-chan time.Time
+
+	if a.index != nil && a.index.refresh > 0 {
+		ticker := time.NewTicker(a.index.refresh)
+		defer ticker.Stop()
+
+		indexTick = ticker.C
+	}
+
+	if a.seo != nil && a.seo.refresh > 0 {
+		ticker := time.NewTicker(a.seo.refresh)
+		defer ticker.Stop()
+
+		seoTick = ticker.C
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-indexTick:
+			a.refreshIndex(ctx)
+
+		case <-seoTick:
+			a.refreshSitemap(ctx)
+
+		case <-hangup:
+			// The sitemap is walked from the fresh index
+			a.refreshIndex(ctx)
+			a.refreshSitemap(ctx)
+		}
+	}
+}
+
+func (a App) refreshIndex(ctx context.Context) {
+	if root := a.indexRoot(); a.index != nil && len(root) != 0 {
+		a.index.update(ctx, root)
+	}
+}
+
+func (a App) refreshSitemap(ctx context.Context) {
+	if root := a.indexRoot(); a.seo != nil && len(root) != 0 {
+		a.seo.update(ctx, a.storage(), root)
+	}
+}
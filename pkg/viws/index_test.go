@@ -0,0 +1,267 @@
+package viws
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileIndex(t *testing.T) {
+	directory := writeSite(t, map[string]string{
+		"index.html":     "<h1>Hello</h1>",
+		"assets/app.js":  "console.log('Ready');",
+		"deleted.html":   "<p>Deleted</p>",
+		"linked/app.css": "body{}",
+	})
+
+	outside := writeSite(t, map[string]string{"shared/app.css": "body{}"})
+
+	if err := os.Symlink(filepath.Join(outside, "shared"), filepath.Join(directory, "shared")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink("nowhere", filepath.Join(directory, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	index := newFileIndex(&Config{Index: true})
+	index.update(context.Background(), directory)
+
+	// Changes are seen only once the index is rebuilt
+	if err := os.Remove(filepath.Join(directory, "deleted.html")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(directory, "created.html"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		name    string
+		wantDir bool
+		wantErr error
+	}{
+		"file": {
+			filepath.Join(directory, "assets/app.js"),
+			false,
+			nil,
+		},
+		"directory": {
+			filepath.Join(directory, "assets"),
+			true,
+			nil,
+		},
+		"root": {
+			directory,
+			true,
+			nil,
+		},
+		"deleted": {
+			filepath.Join(directory, "deleted.html"),
+			false,
+			nil,
+		},
+		"created": {
+			filepath.Join(directory, "created.html"),
+			false,
+			fs.ErrNotExist,
+		},
+		"dangling": {
+			filepath.Join(directory, "dangling"),
+			false,
+			fs.ErrNotExist,
+		},
+		"symlinked directory": {
+			filepath.Join(directory, "shared/app.css"),
+			false,
+			nil,
+		},
+		"outside": {
+			filepath.Join(outside, "shared/app.css"),
+			false,
+			nil,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			info, err := index.Stat(tc.name)
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Stat() = %v, want %v", err, tc.wantErr)
+			}
+
+			if err == nil && info.IsDir() != tc.wantDir {
+				t.Errorf("Stat().IsDir() = %t, want %t", info.IsDir(), tc.wantDir)
+			}
+		})
+	}
+
+	index.update(context.Background(), directory)
+
+	if _, err := index.Stat(filepath.Join(directory, "created.html")); err != nil {
+		t.Errorf("Stat() = %v, want nil after update", err)
+	}
+}
+
+func TestFileIndexWalk(t *testing.T) {
+	directory := writeSite(t, map[string]string{
+		"index.html":      "",
+		"assets/app.js":   "",
+		"assets/app.css":  "",
+		"blog/index.html": "",
+	})
+
+	index := newFileIndex(&Config{Index: true})
+	index.update(context.Background(), directory)
+
+	var got []string
+
+	if err := index.Walk(filepath.Join(directory, "assets"), func(name string, _ fs.FileInfo) error {
+		got = append(got, name)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{filepath.Join(directory, "assets/app.css"), filepath.Join(directory, "assets/app.js")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk() = %v, want %v", got, want)
+	}
+}
+
+func TestHandlerIndex(t *testing.T) {
+	directory := writeSite(t, map[string]string{"index.html": "<h1>Hello</h1>"})
+
+	instance, err := New(&Config{Directory: directory, Index: true}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := httptest.NewRecorder()
+	instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if writer.Code != http.StatusOK {
+		t.Errorf("Status %d, want %d", writer.Code, http.StatusOK)
+	}
+
+	if result, want := writer.Body.String(), "<h1>Hello</h1>"; result != want {
+		t.Errorf("Body `%s`, want `%s`", result, want)
+	}
+
+	// Etag is computed once, so it stays the same even if reading changes the access time of the file
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", writer.Header().Get("Etag"))
+
+	writer = httptest.NewRecorder()
+	instance.Handler().ServeHTTP(writer, req)
+
+	if writer.Code != http.StatusNotModified {
+		t.Errorf("Status %d, want %d", writer.Code, http.StatusNotModified)
+	}
+
+	writer = httptest.NewRecorder()
+	instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/missing.html", nil))
+
+	if writer.Code != http.StatusNotFound {
+		t.Errorf("Status %d, want %d", writer.Code, http.StatusNotFound)
+	}
+}
+
+func TestStartIndex(t *testing.T) {
+	directory := writeSite(t, map[string]string{"index.html": "<h1>Hello</h1>"})
+
+	instance, err := New(&Config{Directory: directory, Index: true, IndexRefresh: 10 * time.Millisecond}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go instance.Start(ctx)
+
+	if err := os.WriteFile(filepath.Join(directory, "about.html"), []byte("<p>About</p>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		writer := httptest.NewRecorder()
+		instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/about.html", nil))
+
+		if writer.Code == http.StatusOK {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Status %d, want %d once index is refreshed", writer.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestHandlerIndexDeleted(t *testing.T) {
+	cases := map[string]struct {
+		config     Config
+		wantStatus int
+		want       string
+	}{
+		"not found": {
+			Config{Index: true},
+			http.StatusNotFound,
+			"<p>Not found</p>",
+		},
+		"single page application": {
+			Config{Index: true, Spa: true},
+			http.StatusOK,
+			"<h1>Hello</h1>",
+		},
+		"nonce": {
+			Config{Index: true, Nonce: true},
+			http.StatusNotFound,
+			"<p>Not found</p>",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			directory := writeSite(t, map[string]string{
+				"index.html":   "<h1>Hello</h1>",
+				"404.html":     "<p>Not found</p>",
+				"deleted.html": "<p>Deleted</p>",
+			})
+
+			tc.config.Directory = directory
+
+			instance, err := New(&tc.config, nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.Remove(filepath.Join(directory, "deleted.html")); err != nil {
+				t.Fatal(err)
+			}
+
+			writer := httptest.NewRecorder()
+			instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/deleted.html", nil))
+
+			if writer.Code != tc.wantStatus {
+				t.Errorf("Status %d, want %d", writer.Code, tc.wantStatus)
+			}
+
+			if result := writer.Body.String(); result != tc.want {
+				t.Errorf("Body `%s`, want `%s`", result, tc.want)
+			}
+
+			if etag := writer.Header().Get("Etag"); len(etag) != 0 && tc.wantStatus == http.StatusNotFound {
+				t.Errorf("Etag `%s`, want none on a missing file", etag)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package viws
+
+import (
+	"net/http"
+)
+
+const allowedMethods = "GET, HEAD, OPTIONS"
+
+// allowMethod answers OPTIONS and refuses methods other than GET or HEAD. It returns false when the response is
+// written.
+func allowMethod(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+
+	case http.MethodOptions:
+		w.Header().Set("Allow", allowedMethods)
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		w.Header().Set("Allow", allowedMethods)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+
+	return false
+}
+
+// headResponseWriter discards the body, so that a HEAD request goes through the same path as a GET and gets the
+// exact same headers.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (hrw headResponseWriter) Write(content []byte) (int, error) {
+	return len(content), nil
+}
+
+func (hrw headResponseWriter) Unwrap() http.ResponseWriter {
+	return hrw.ResponseWriter
+}
@@ -0,0 +1,80 @@
+package viws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ViBiOh/viws/pkg/compress"
+)
+
+func TestHeadHeaders(t *testing.T) {
+	compressService, err := compress.New(&compress.Config{
+		Enabled:   true,
+		Encodings: []string{compress.Gzip},
+		Types:     []string{"text/css"},
+		GzipLevel: 6,
+		CacheSize: 1 << 20,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := New(&Config{Directory: exampleDir, Spa: true, Headers: []string{"content-language:fr"}}, nil, nil, nil, compressService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		path           string
+		acceptEncoding string
+	}{
+		"index": {
+			"/",
+			"",
+		},
+		"file": {
+			"/index.js",
+			"",
+		},
+		"compressed": {
+			"/index.css",
+			"gzip",
+		},
+		"spa fallback": {
+			"/user/1234",
+			"",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			responses := make(map[string]*httptest.ResponseRecorder)
+
+			for _, method := range []string{http.MethodGet, http.MethodHead} {
+				request := httptest.NewRequest(method, tc.path, nil)
+				request.Header.Set("Accept-Encoding", tc.acceptEncoding)
+
+				writer := httptest.NewRecorder()
+				instance.Handler().ServeHTTP(writer, request)
+
+				responses[method] = writer
+			}
+
+			get, head := responses[http.MethodGet], responses[http.MethodHead]
+
+			if head.Code != get.Code {
+				t.Errorf("HEAD status %d, want %d", head.Code, get.Code)
+			}
+
+			if !reflect.DeepEqual(head.Header(), get.Header()) {
+				t.Errorf("HEAD headers = %+v, want %+v", head.Header(), get.Header())
+			}
+
+			if head.Body.Len() != 0 {
+				t.Errorf("HEAD body = `%s`, want none", head.Body.String())
+			}
+		})
+	}
+}
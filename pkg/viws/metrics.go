@@ -0,0 +1,83 @@
+package viws
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ViBiOh/viws/pkg/metrics"
+	"github.com/ViBiOh/viws/pkg/recorder"
+)
+
+// statusClasses are the labels of status classes, indexed by status / 100.
+var statusClasses = [...]string{"", "1xx", "2xx", "3xx", "4xx", "5xx"}
+
+type appMetrics struct {
+	requests    [len(statusClasses)]*metrics.Counter
+	durations   [len(statusClasses)]*metrics.Histogram
+	bytes       *metrics.Counter
+	spa         *metrics.Counter
+	notFound    *metrics.Counter
+	notModified *metrics.Counter
+}
+
+func newAppMetrics(registry *metrics.Registry) *appMetrics {
+	if registry == nil {
+		return nil
+	}
+
+	output := &appMetrics{
+		bytes:       registry.Counter("viws_response_content_bytes_total", "Bytes of response bodies served, before compression"),
+		spa:         registry.Counter("viws_spa_fallbacks_total", "Requests served with index.html in Single Page Application mode"),
+		notFound:    registry.Counter("viws_not_found_total", "Requests answered with a 404"),
+		notModified: registry.Counter("viws_not_modified_total", "Requests answered with a 304 by ETag or modification date"),
+	}
+
+	for index, class := range statusClasses {
+		if len(class) == 0 {
+			continue
+		}
+
+		output.requests[index] = registry.Counter("viws_requests_total", "Requests served by status class", "class", class)
+		output.durations[index] = registry.Histogram("viws_request_duration_seconds", "Duration of requests by status class", metrics.DefaultBuckets, "class", class)
+	}
+
+	return output
+}
+
+func (m *appMetrics) spaFallback() {
+	if m != nil {
+		m.spa.Inc()
+	}
+}
+
+// instrument wraps the handler to record the outcome of each request.
+func (m *appMetrics) instrument(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		writer := recorder.New(w)
+
+		next.ServeHTTP(writer, r)
+
+		m.observe(writer.Status(), writer.Bytes(), time.Since(start))
+	})
+}
+
+func (m *appMetrics) observe(status int, bytes int64, duration time.Duration) {
+	switch status {
+	case http.StatusNotFound:
+		m.notFound.Inc()
+	case http.StatusNotModified:
+		m.notModified.Inc()
+	}
+
+	m.bytes.Add(uint64(bytes))
+
+	if class := status / 100; class > 0 && class < len(statusClasses) {
+		m.requests[class].Inc()
+		m.durations[class].Observe(duration.Seconds())
+	}
+}
@@ -0,0 +1,57 @@
+package viws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ViBiOh/viws/pkg/metrics"
+)
+
+func TestHandlerMetrics(t *testing.T) {
+	registry := metrics.New(&metrics.Config{Path: "/metrics"})
+
+	spa, err := New(&Config{Directory: exampleDir, Spa: true}, nil, registry, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	static, err := New(&Config{Directory: exampleDir}, nil, registry, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writer := httptest.NewRecorder()
+	spa.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/index.js", nil))
+	etag := writer.Header().Get("Etag")
+
+	conditional := httptest.NewRequest(http.MethodGet, "/index.js", nil)
+	conditional.Header.Set("If-None-Match", etag)
+	spa.Handler().ServeHTTP(httptest.NewRecorder(), conditional)
+
+	spa.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/", nil))
+	static.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+
+	var output strings.Builder
+	registry.Write(&output)
+	result := output.String()
+
+	for _, want := range []string{
+		`viws_requests_total{class="2xx"} 2`,
+		`viws_requests_total{class="3xx"} 1`,
+		`viws_requests_total{class="4xx"} 1`,
+		`viws_request_duration_seconds_count{class="2xx"} 2`,
+		"viws_not_modified_total 1",
+		"viws_not_found_total 1",
+		"viws_spa_fallbacks_total 1",
+	} {
+		if !strings.Contains(result, want+"\n") {
+			t.Errorf("metrics don't contain `%s`:\n%s", want, result)
+		}
+	}
+
+	if strings.Contains(result, "viws_response_content_bytes_total 0\n") {
+		t.Errorf("no bytes served:\n%s", result)
+	}
+}
@@ -2,36 +2,53 @@ package viws
 
 import (
 	"bytes"
-	"context"
 	"errors"
 	"io"
 	"io/fs"
 	"log/slog"
 	"mime"
 	"net/http"
-	"os"
+	"strconv"
 
 	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+	"github.com/ViBiOh/viws/pkg/accesslog"
 )
 
-func (a App) serveNotFound(ctx context.Context, w http.ResponseWriter) {
-	notFoundPath, _, err := getFileToServe(a.directory, notFoundFilename)
+func (a App) serveNotFound(w http.ResponseWriter, r *http.Request) {
+	notFoundPath, _, err := getFileToServe(a.storage(), a.directory, notFoundFilename)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			err = nil
 		}
 
-		httperror.NotFound(ctx, w, err)
+		httperror.NotFound(r.Context(), w, err)
 		return
 	}
 
-	a.serve(ctx, w, http.StatusNotFound, notFoundPath)
+	a.serve(w, r, http.StatusNotFound, notFoundPath)
 }
 
-func (a App) serve(ctx context.Context, w http.ResponseWriter, status int, filename string) {
-	file, err := os.Open(filename)
+func (a App) serve(w http.ResponseWriter, r *http.Request, status int, filename string) {
+	ctx := r.Context()
+	accesslog.SetFile(ctx, filename)
+
+	// The page may have been deleted since the index was built
+	if a.dynamicHTML() {
+		if !a.serveDynamic(w, r, status, filename) {
+			httperror.NotFound(ctx, w, nil)
+		}
+
+		return
+	}
+
+	file, err := a.storage().Open(filename)
 	if err != nil {
-		httperror.InternalServerError(ctx, w, err)
+		if errors.Is(err, fs.ErrNotExist) {
+			httperror.NotFound(ctx, w, nil)
+		} else {
+			httperror.InternalServerError(ctx, w, err)
+		}
+
 		return
 	}
 
@@ -41,6 +58,11 @@ func (a App) serve(ctx context.Context, w http.ResponseWriter, status int, filen
 		}
 	}()
 
+	if info, err := file.Stat(); err == nil {
+		a.addInlineHashes(ctx, w, filename, info.ModTime())
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+
 	contentType := mime.TypeByExtension(filename)
 	if len(contentType) == 0 {
 		contentType = "text/html; charset=utf-8"
@@ -0,0 +1,170 @@
+package viws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const linkHeader = "Link"
+
+var (
+	linkTagRegex   = regexp.MustCompile(`(?i)<link\s[^>]*>`)
+	attributeRegex = regexp.MustCompile(`([a-zA-Z-]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+)
+
+// loadPreloadManifest reads a JSON object of served file (e.g. `/index.html`) to list of assets to preload. An asset is
+// either an URL, the destination being guessed from its extension, or a complete Link header value.
+func loadPreloadManifest(filename string) (map[string][]string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+
+	var manifest map[string][]string
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+
+	output := make(map[string][]string, len(manifest))
+
+	for page, assets := range manifest {
+		links := make([]string, 0, len(assets))
+
+		for _, asset := range assets {
+			if strings.HasPrefix(asset, "<") {
+				links = append(links, asset)
+			} else {
+				links = append(links, preloadLink(asset, guessDestination(asset), "", false))
+			}
+		}
+
+		output[path.Join("/", page)] = links
+	}
+
+	return output, nil
+}
+
+func guessDestination(url string) string {
+	url, _, _ = strings.Cut(url, "?")
+
+	switch strings.ToLower(path.Ext(url)) {
+	case ".css":
+		return "style"
+	case ".js", ".mjs":
+		return "script"
+	case ".woff", ".woff2", ".ttf", ".otf":
+		return "font"
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".svg", ".ico":
+		return "image"
+	default:
+		return "fetch"
+	}
+}
+
+func preloadLink(url, destination, mimeType string, crossOrigin bool) string {
+	var builder strings.Builder
+
+	_, _ = fmt.Fprintf(&builder, "<%s>; rel=preload", url)
+
+	if len(destination) != 0 {
+		_, _ = fmt.Fprintf(&builder, "; as=%s", destination)
+	}
+
+	if len(mimeType) != 0 {
+		_, _ = fmt.Fprintf(&builder, `; type="%s"`, mimeType)
+	}
+
+	// Fonts are always fetched in CORS mode, so the preload must be too for the browser to reuse it.
+	if crossOrigin || destination == "font" {
+		builder.WriteString("; crossorigin")
+	}
+
+	return builder.String()
+}
+
+// parsePreloadLinks extracts `<link rel="preload">` and `<link rel="modulepreload">` of an HTML document as Link header values.
+func parsePreloadLinks(content []byte) []string {
+	var output []string
+
+	for _, tag := range linkTagRegex.FindAll(content, -1) {
+		attributes := make(map[string]string)
+
+		for _, attribute := range attributeRegex.FindAllSubmatch(tag[len("<link"):], -1) {
+			attributes[strings.ToLower(string(attribute[1]))] = string(attribute[2]) + string(attribute[3]) + string(attribute[4])
+		}
+
+		href := attributes["href"]
+		if len(href) == 0 {
+			continue
+		}
+
+		_, crossOrigin := attributes["crossorigin"]
+
+		for rel := range strings.FieldsSeq(strings.ToLower(attributes["rel"])) {
+			switch rel {
+			case "preload":
+				output = append(output, preloadLink(href, attributes["as"], attributes["type"], crossOrigin))
+			case "modulepreload":
+				output = append(output, fmt.Sprintf("<%s>; rel=modulepreload", href))
+			}
+		}
+	}
+
+	return output
+}
+
+func (a App) preloadLinks(ctx context.Context, filename string, modTime time.Time) []string {
+	if a.preloadManifest != nil {
+		relative, err := filepath.Rel(a.directory, filename)
+		if err != nil {
+			return nil
+		}
+
+		return a.preloadManifest[path.Join("/", filepath.ToSlash(relative))]
+	}
+
+	if a.preloadCache == nil || !isHTML(filename) {
+		return nil
+	}
+
+	links, err := a.preloadCache.get(a.storage(), filename, modTime)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "parse preload links", slog.String("filename", filename), slog.Any("error", err))
+	}
+
+	return links
+}
+
+// addPreloadLinks adds Link headers for the file, returning true when some were added.
+func (a App) addPreloadLinks(ctx context.Context, w http.ResponseWriter, filename string, modTime time.Time) bool {
+	links := a.preloadLinks(ctx, filename, modTime)
+
+	for _, link := range links {
+		w.Header().Add(linkHeader, link)
+	}
+
+	return len(links) != 0
+}
+
+// flushEarlyHints sends the Link headers already set as a 103 Early Hints, they are kept for the final response.
+func flushEarlyHints(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoAtLeast(1, 1) {
+		w.WriteHeader(http.StatusEarlyHints)
+	}
+}
+
+// sendEarlyHints adds Link headers for the file and flushes them as a 103 Early Hints, they are kept for the final response.
+func (a App) sendEarlyHints(w http.ResponseWriter, r *http.Request, filename string, modTime time.Time) {
+	if a.addPreloadLinks(r.Context(), w, filename, modTime) {
+		flushEarlyHints(w, r)
+	}
+}
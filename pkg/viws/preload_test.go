@@ -0,0 +1,113 @@
+package viws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParsePreloadLinks(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		want  []string
+	}{
+		"no link": {
+			`<link rel="stylesheet" href="/index.css">`,
+			nil,
+		},
+		"preload": {
+			`<link rel="preload" href="/index.css" as="style"><LINK href='/font.woff2' rel=preload as=font type="font/woff2" crossorigin>`,
+			[]string{
+				"</index.css>; rel=preload; as=style",
+				`</font.woff2>; rel=preload; as=font; type="font/woff2"; crossorigin`,
+			},
+		},
+		"modulepreload": {
+			`<link rel="modulepreload" href="/app.mjs">`,
+			[]string{"</app.mjs>; rel=modulepreload"},
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if result := parsePreloadLinks([]byte(tc.input)); !reflect.DeepEqual(result, tc.want) {
+				t.Errorf("parsePreloadLinks() = %q, want %q", result, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadPreloadManifest(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "preload.json")
+	if err := os.WriteFile(manifest, []byte(`{"index.html":["/index.css","/index.js?v=1","</data.json>; rel=preload; as=fetch; crossorigin"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := loadPreloadManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"/index.html": {
+			"</index.css>; rel=preload; as=style",
+			"</index.js?v=1>; rel=preload; as=script",
+			"</data.json>; rel=preload; as=fetch; crossorigin",
+		},
+	}
+
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("loadPreloadManifest() = %q, want %q", result, want)
+	}
+}
+
+func TestEarlyHints(t *testing.T) {
+	instance := App{
+		directory:       exampleDir,
+		preloadManifest: map[string][]string{"/index.html": {"</index.css>; rel=preload; as=style"}},
+	}
+
+	server := httptest.NewServer(instance.Handler())
+	defer server.Close()
+
+	var hints []string
+
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, header.Values(linkHeader)...)
+			}
+
+			return nil
+		},
+	})
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/", nil)
+
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+
+	want := []string{"</index.css>; rel=preload; as=style"}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if !reflect.DeepEqual(hints, want) {
+		t.Errorf("Early Hints = %q, want %q", hints, want)
+	}
+
+	if result := resp.Header.Values(linkHeader); !reflect.DeepEqual(result, want) {
+		t.Errorf("Link = %q, want %q", result, want)
+	}
+}
@@ -0,0 +1,69 @@
+package viws
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+)
+
+const (
+	previewPlaceholder = "{sub}"
+	robotsTagHeader    = "X-Robots-Tag"
+)
+
+var (
+	errInvalidPreviewHost      = errors.New("preview host must contain `" + previewPlaceholder + "` once")
+	errInvalidPreviewDirectory = errors.New("preview directory must contain `" + previewPlaceholder + "`")
+	previewNameRegex           = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
+// preview maps a host pattern (e.g. `{sub}.preview.example.com`) to a directory template (e.g. `/previews/{sub}`).
+type preview struct {
+	host      *regexp.Regexp
+	directory string
+}
+
+func newPreview(hostPattern, directory string) (*preview, error) {
+	if len(hostPattern) == 0 {
+		return nil, nil
+	}
+
+	before, after, ok := strings.Cut(strings.ToLower(hostPattern), previewPlaceholder)
+	if !ok || strings.Contains(after, previewPlaceholder) {
+		return nil, errInvalidPreviewHost
+	}
+
+	if !strings.Contains(directory, previewPlaceholder) {
+		return nil, errInvalidPreviewDirectory
+	}
+
+	return &preview{
+		host:      regexp.MustCompile("^" + regexp.QuoteMeta(before) + "([^.]+)" + regexp.QuoteMeta(after) + "$"),
+		directory: directory,
+	}, nil
+}
+
+// match returns the directory of the preview for the given host, valid is false when host matches the pattern but
+// the captured name is not a safe directory name.
+func (p *preview) match(host string) (directory string, matched, valid bool) {
+	if p == nil {
+		return "", false, false
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	matches := p.host.FindStringSubmatch(strings.ToLower(host))
+	if len(matches) != 2 {
+		return "", false, false
+	}
+
+	name := matches[1]
+	if !previewNameRegex.MatchString(name) {
+		return "", true, false
+	}
+
+	return strings.ReplaceAll(p.directory, previewPlaceholder, name), true, true
+}
@@ -0,0 +1,123 @@
+package viws
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewPreview(t *testing.T) {
+	cases := map[string]struct {
+		host      string
+		directory string
+		wantErr   error
+	}{
+		"disabled": {
+			"",
+			"/previews/{sub}",
+			nil,
+		},
+		"no placeholder in host": {
+			"preview.example.com",
+			"/previews/{sub}",
+			errInvalidPreviewHost,
+		},
+		"twice placeholder in host": {
+			"{sub}.{sub}.example.com",
+			"/previews/{sub}",
+			errInvalidPreviewHost,
+		},
+		"no placeholder in directory": {
+			"{sub}.preview.example.com",
+			"/previews",
+			errInvalidPreviewDirectory,
+		},
+		"valid": {
+			"{sub}.preview.example.com",
+			"/previews/{sub}",
+			nil,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			if _, err := newPreview(tc.host, tc.directory); !errors.Is(err, tc.wantErr) {
+				t.Errorf("newPreview() = %s, want %s", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPreviewMatch(t *testing.T) {
+	instance, err := newPreview("{sub}.preview.example.com", "/previews/{sub}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		instance      *preview
+		host          string
+		wantDirectory string
+		wantMatched   bool
+		wantValid     bool
+	}{
+		"disabled": {
+			nil,
+			"feat.preview.example.com",
+			"",
+			false,
+			false,
+		},
+		"other host": {
+			instance,
+			"www.example.com",
+			"",
+			false,
+			false,
+		},
+		"nested subdomain": {
+			instance,
+			"a.b.preview.example.com",
+			"",
+			false,
+			false,
+		},
+		"with port": {
+			instance,
+			"feat-42.preview.example.com:1080",
+			"/previews/feat-42",
+			true,
+			true,
+		},
+		"uppercase": {
+			instance,
+			"Feat-42.Preview.Example.com",
+			"/previews/feat-42",
+			true,
+			true,
+		},
+		"leading dash": {
+			instance,
+			"-feat.preview.example.com",
+			"",
+			true,
+			false,
+		},
+		"unsafe characters": {
+			instance,
+			"feat_%2e%2e.preview.example.com",
+			"",
+			true,
+			false,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			directory, matched, valid := tc.instance.match(tc.host)
+
+			if directory != tc.wantDirectory || matched != tc.wantMatched || valid != tc.wantValid {
+				t.Errorf("match() = (`%s`, %t, %t), want (`%s`, %t, %t)", directory, matched, valid, tc.wantDirectory, tc.wantMatched, tc.wantValid)
+			}
+		})
+	}
+}
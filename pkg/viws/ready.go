@@ -0,0 +1,99 @@
+package viws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+var errNoActiveRelease = errors.New("no active release")
+
+// Ready checks that the served content is healthy: the directory exists, required files are readable and every file
+// listed in the manifest is present. It is meant to be a readiness check, so that no traffic is routed to an instance
+// whose volume is empty or failed to mount.
+func (a App) Ready(_ context.Context) error {
+	if a.releases != nil {
+		active := a.releases.Active()
+		if len(active.ID) == 0 {
+			return errNoActiveRelease
+		}
+
+		a.directory = active.Directory
+	}
+
+	files := a.storage()
+
+	info, err := files.Stat(a.directory)
+	if err != nil {
+		return fmt.Errorf("directory: %w", err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("directory: `%s` is not a directory", a.directory)
+	}
+
+	var errs []error
+
+	for _, name := range a.readyFiles {
+		if err := readable(files, filepath.Join(a.directory, name)); err != nil {
+			errs = append(errs, fmt.Errorf("`%s`: %w", name, err))
+		}
+	}
+
+	if len(a.readyManifest) != 0 {
+		if err := a.checkManifest(files); err != nil {
+			errs = append(errs, fmt.Errorf("manifest: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func readable(files storage, filename string) error {
+	file, err := files.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Read(make([]byte, 1))
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+
+	return errors.Join(err, file.Close())
+}
+
+// checkManifest reads the manifest from the served directory, a JSON array of paths or an object of names to paths,
+// and checks that every path exists.
+func (a App) checkManifest(files storage) error {
+	content, err := readFile(files, filepath.Join(a.directory, a.readyManifest))
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	var paths []string
+
+	if err := json.Unmarshal(content, &paths); err != nil {
+		var named map[string]string
+		if err := json.Unmarshal(content, &named); err != nil {
+			return fmt.Errorf("parse: expecting an array of paths or an object of names to paths: %w", err)
+		}
+
+		for _, path := range named {
+			paths = append(paths, path)
+		}
+	}
+
+	var errs []error
+
+	for _, path := range paths {
+		if _, err := files.Stat(filepath.Join(a.directory, path)); err != nil {
+			errs = append(errs, fmt.Errorf("`%s`: %w", path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
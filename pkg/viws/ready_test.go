@@ -0,0 +1,136 @@
+package viws
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ViBiOh/viws/pkg/release"
+)
+
+func TestReady(t *testing.T) {
+	directory := t.TempDir()
+
+	files := map[string]string{
+		"index.html":       "<h1>Hello</h1>",
+		"assets/app.js":    "console.log('Ready');",
+		"valid.json":       `["/index.html", "assets/app.js"]`,
+		"named.json":       `{"app.js": "/assets/app.js"}`,
+		"incomplete.json":  `{"app.js": "/assets/app.js", "app.css": "/assets/app.css"}`,
+		"invalid.json":     `"/index.html"`,
+		"empty/index.html": "",
+	}
+
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Join(directory, filepath.Dir(name)), 0o700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[string]struct {
+		config  Config
+		wantErr bool
+	}{
+		"valid": {
+			Config{Directory: directory, ReadyFiles: []string{indexFilename}},
+			false,
+		},
+		"empty file": {
+			Config{Directory: filepath.Join(directory, "empty"), ReadyFiles: []string{indexFilename}},
+			false,
+		},
+		"no index": {
+			Config{Directory: filepath.Join(directory, "assets")},
+			false,
+		},
+		"missing directory": {
+			Config{Directory: filepath.Join(directory, "unmounted"), ReadyFiles: []string{indexFilename}},
+			true,
+		},
+		"not a directory": {
+			Config{Directory: filepath.Join(directory, indexFilename)},
+			true,
+		},
+		"missing file": {
+			Config{Directory: directory, ReadyFiles: []string{indexFilename, notFoundFilename}},
+			true,
+		},
+		"manifest array": {
+			Config{Directory: directory, ReadyManifest: "valid.json"},
+			false,
+		},
+		"manifest object": {
+			Config{Directory: directory, ReadyManifest: "named.json"},
+			false,
+		},
+		"incomplete manifest": {
+			Config{Directory: directory, ReadyManifest: "incomplete.json"},
+			true,
+		},
+		"invalid manifest": {
+			Config{Directory: directory, ReadyManifest: "invalid.json"},
+			true,
+		},
+		"missing manifest": {
+			Config{Directory: directory, ReadyManifest: "manifest.json"},
+			true,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			instance, err := New(&tc.config, nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := instance.Ready(context.Background()); (err != nil) != tc.wantErr {
+				t.Errorf("Ready() = %v, wantErr %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadyRelease(t *testing.T) {
+	root := t.TempDir()
+
+	directory := filepath.Join(root, "releases", "v1")
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	releases, err := release.New(&release.Config{Directory: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := New(&Config{ReadyFiles: []string{indexFilename}}, releases, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := instance.Ready(context.Background()); err == nil {
+		t.Error("Ready() = nil, want error without active release")
+	}
+
+	if err := releases.Activate("v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := instance.Ready(context.Background()); err == nil {
+		t.Error("Ready() = nil, want error without index in active release")
+	}
+
+	if err := os.WriteFile(filepath.Join(directory, indexFilename), []byte("v1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := instance.Ready(context.Background()); err != nil {
+		t.Errorf("Ready() = %v, want nil", err)
+	}
+}
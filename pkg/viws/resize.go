@@ -0,0 +1,276 @@
+package viws
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/hash"
+	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+	"github.com/ViBiOh/viws/pkg/accesslog"
+	"github.com/ViBiOh/viws/pkg/cache"
+	"github.com/ViBiOh/viws/pkg/metrics"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	widthParam  = "w"
+	formatParam = "fmt"
+
+	// maxPixels bounds the memory used to decode a source image, a few bytes per pixel.
+	maxPixels = 50_000_000
+)
+
+var (
+	errUnsupportedFormat = errors.New("unsupported format, expecting jpeg, png or gif")
+	errImageTooLarge     = errors.New("image too large to be resized")
+)
+
+// resizer generates resized variants of images, restricted to allowed widths. Variants are kept in memory and, when
+// a directory is given, on disk so that they survive restarts. Slots bound the images decoded at once.
+type resizer struct {
+	widths  map[int]bool
+	cache   *cache.Cache
+	disk    *diskCache
+	slots   chan struct{}
+	quality int
+}
+
+func newResizer(config *Config, registry *metrics.Registry) (*resizer, error) {
+	if len(config.ResizeWidths) == 0 {
+		return nil, nil
+	}
+
+	if config.ResizeQuality < 1 || config.ResizeQuality > 100 {
+		return nil, fmt.Errorf("invalid quality %d, expecting 1 to 100", config.ResizeQuality)
+	}
+
+	concurrency := int(config.ResizeConcurrency)
+	if concurrency == 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	output := &resizer{
+		widths:  make(map[int]bool),
+		cache:   cache.New(config.ResizeCacheSize, registry, "resize"),
+		slots:   make(chan struct{}, concurrency),
+		quality: config.ResizeQuality,
+	}
+
+	for _, value := range config.ResizeWidths {
+		width, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("invalid width `%s`", value)
+		}
+
+		output.widths[width] = true
+	}
+
+	disk, err := newDiskCache(config.ResizeCacheDirectory, config.ResizeDiskSize)
+	if err != nil {
+		return nil, fmt.Errorf("cache directory: %w", err)
+	}
+
+	output.disk = disk
+
+	return output, nil
+}
+
+func isResizable(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return true
+	default:
+		return false
+	}
+}
+
+// wantsResize checks that the request targets an image with resizing parameters.
+func (r *resizer) wantsResize(req *http.Request, filename string) bool {
+	if r == nil || !isResizable(filename) {
+		return false
+	}
+
+	query := req.URL.Query()
+
+	return query.Has(widthParam) || query.Has(formatParam)
+}
+
+// params validates the requested width and format, the format of the source being kept by default.
+func (r *resizer) params(req *http.Request, filename string) (int, string, error) {
+	query := req.URL.Query()
+
+	var width int
+	if value := query.Get(widthParam); len(value) != 0 {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || !r.widths[parsed] {
+			return 0, "", fmt.Errorf("width `%s` is not allowed", value)
+		}
+
+		width = parsed
+	}
+
+	format := strings.ToLower(query.Get(formatParam))
+	if len(format) == 0 {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	}
+
+	switch format {
+	case "jpg", "jpeg":
+		return width, "jpeg", nil
+	case "png", "gif":
+		return width, format, nil
+	case "webp":
+		// Only decoding of WebP is available in pure Go, its resized variants are sent as PNG
+		if len(query.Get(formatParam)) == 0 {
+			return width, "png", nil
+		}
+	}
+
+	return 0, "", errUnsupportedFormat
+}
+
+func (r *resizer) resize(source []byte, width int, format string) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(source))
+	if err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+
+	if config.Width*config.Height > maxPixels {
+		return nil, errImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(source))
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	bounds := img.Bounds()
+
+	// Images are never enlarged
+	if width != 0 && width < bounds.Dx() {
+		height := max(bounds.Dy()*width/bounds.Dx(), 1)
+
+		scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+
+		img = scaled
+	}
+
+	var output bytes.Buffer
+
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&output, img, &jpeg.Options{Quality: r.quality})
+	case "png":
+		err = png.Encode(&output, img)
+	case "gif":
+		err = gif.Encode(&output, img, nil)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("encode: %w", err)
+	}
+
+	return output.Bytes(), nil
+}
+
+// variant returns the resized image from cache, from disk or by resizing the source. The version identifies the
+// source file and the parameters.
+func (r *resizer) variant(files storage, filename, version string, width int, format string) ([]byte, error) {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s|%s", filename, version))
+	key := hex.EncodeToString(sum[:])
+
+	return r.cache.Get(key, func() ([]byte, error) {
+		diskFile := key + "." + format
+
+		if content, ok := r.disk.get(diskFile); ok {
+			return content, nil
+		}
+
+		source, err := readFile(files, filename)
+		if err != nil {
+			return nil, fmt.Errorf("read: %w", err)
+		}
+
+		r.slots <- struct{}{}
+		content, err := r.resize(source, width, format)
+		<-r.slots
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.disk.set(diskFile, content)
+
+		return content, nil
+	})
+}
+
+func (a App) serveResized(w http.ResponseWriter, r *http.Request, filename string, info os.FileInfo) {
+	accesslog.SetFile(r.Context(), filename)
+
+	width, format, err := a.resizer.params(r, filename)
+	if err != nil {
+		httperror.BadRequest(r.Context(), w, err)
+		return
+	}
+
+	a.addCustomHeaders(w)
+
+	version := hash.String(fmt.Sprintf("%s|%d|%d|%d|%s", info.Name(), info.Size(), info.ModTime().UnixNano(), width, format))
+
+	modTime := info.ModTime()
+
+	if a.dev == nil {
+		setCacheHeader(w, r)
+		w.Header().Add("Etag", weakETag(version))
+
+		// Conditions are checked before resizing, which is the costly part
+		if checkConditions(w, r, weakETag(version), modTime) {
+			return
+		}
+	}
+
+	content, err := a.resizer.variant(a.storage(), filename, version, width, format)
+	if err != nil {
+		// An error is never cached
+		w.Header().Del(cacheControlHeader)
+		w.Header().Del("Etag")
+
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// Deleted since the index was built
+			a.serveNotFound(w, r)
+		case errors.Is(err, errImageTooLarge):
+			httperror.BadRequest(r.Context(), w, err)
+		default:
+			httperror.InternalServerError(r.Context(), w, fmt.Errorf("resize: %w", err))
+		}
+
+		return
+	}
+
+	if a.dev != nil {
+		w.Header().Set(cacheControlHeader, noStoreValue)
+		modTime = time.Time{}
+	}
+
+	w.Header().Set("Content-Type", "image/"+format)
+	http.ServeContent(w, r, filename, modTime, bytes.NewReader(content))
+}
@@ -0,0 +1,167 @@
+package viws
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskCache stores resized images in a directory, the least recently used being removed once over its budget. A read
+// refreshes the modification time of the file, that orders removals.
+type diskCache struct {
+	directory string
+	maxBytes  int64
+	bytes     int64
+	mutex     sync.Mutex
+}
+
+func newDiskCache(directory string, maxBytes uint) (*diskCache, error) {
+	if len(directory) == 0 {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(directory, 0o700); err != nil {
+		return nil, fmt.Errorf("create: %w", err)
+	}
+
+	output := &diskCache{
+		directory: directory,
+		maxBytes:  int64(maxBytes),
+	}
+
+	// The budget may have been lowered since files were stored
+	if err := output.prune(); err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+func (c *diskCache) get(name string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	filename := filepath.Join(c.directory, name)
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(filename, now, now); err != nil {
+		slog.Warn("touch resized image", "file", filename, "error", err)
+	}
+
+	return content, true
+}
+
+func (c *diskCache) set(name string, content []byte) {
+	if c == nil || (c.maxBytes != 0 && int64(len(content)) > c.maxBytes) {
+		return
+	}
+
+	filename := filepath.Join(c.directory, name)
+
+	if err := writeAtomically(filename, content); err != nil {
+		slog.Error("store resized image", "file", filename, "error", err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.bytes += int64(len(content))
+
+	if c.maxBytes != 0 && c.bytes > c.maxBytes {
+		if err := c.pruneLocked(); err != nil {
+			slog.Error("prune resized images", "dir", c.directory, "error", err)
+		}
+	}
+}
+
+func (c *diskCache) prune() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.pruneLocked()
+}
+
+// pruneLocked measures the directory, as it's the truth when a file is stored twice, and removes the least recently
+// used files until it fits the budget.
+func (c *diskCache) pruneLocked() error {
+	entries, err := os.ReadDir(c.directory)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	var files []os.FileInfo
+	var total int64
+
+	for _, entry := range entries {
+		// Temporary files are being written
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		files = append(files, info)
+		total += info.Size()
+	}
+
+	if c.maxBytes != 0 && total > c.maxBytes {
+		slices.SortFunc(files, func(a, b os.FileInfo) int {
+			return a.ModTime().Compare(b.ModTime())
+		})
+
+		for _, info := range files {
+			if total <= c.maxBytes {
+				break
+			}
+
+			if err := os.Remove(filepath.Join(c.directory, info.Name())); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("remove: %w", err)
+			}
+
+			total -= info.Size()
+		}
+	}
+
+	c.bytes = total
+
+	return nil
+}
+
+// writeAtomically writes a temporary file then renames it, so that a concurrent reader never sees a partial file.
+func writeAtomically(filename string, content []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(filename), ".resize-*")
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+
+	if _, err = file.Write(content); err == nil {
+		err = file.Close()
+	} else {
+		_ = file.Close()
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), filename)
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,57 @@
+package viws
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskCache(t *testing.T) {
+	directory := t.TempDir()
+
+	disk, err := newDiskCache(directory, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disk.set("first.png", []byte("1234"))
+	disk.set("second.png", []byte("1234"))
+
+	// The first one is read, the second one becomes the least recently used
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(directory, "second.png"), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, ok := disk.get("first.png"); !ok || string(content) != "1234" {
+		t.Errorf("get() = (`%s`, %t), want `1234`", content, ok)
+	}
+
+	disk.set("third.png", []byte("1234"))
+
+	if _, ok := disk.get("second.png"); ok {
+		t.Error("get() of least recently used = true, want false")
+	}
+
+	for _, name := range []string{"first.png", "third.png"} {
+		if _, ok := disk.get(name); !ok {
+			t.Errorf("get(`%s`) = false, want true", name)
+		}
+	}
+
+	disk.set("large.png", []byte("12345678901"))
+
+	if _, ok := disk.get("large.png"); ok {
+		t.Error("get() of image larger than budget = true, want false")
+	}
+
+	shrunk, err := newDiskCache(directory, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if shrunk.bytes != 4 {
+		t.Errorf("newDiskCache() with lower budget = %d bytes, want 4", shrunk.bytes)
+	}
+}
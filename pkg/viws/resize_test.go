@@ -0,0 +1,152 @@
+package viws
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServeResized(t *testing.T) {
+	directory := t.TempDir()
+	cacheDirectory := t.TempDir()
+
+	source := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	for x := range 100 {
+		for y := range 50 {
+			source.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var content bytes.Buffer
+	if err := png.Encode(&content, source); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(directory, "photo.png"), content.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := New(&Config{
+		Directory:            directory,
+		ResizeWidths:         []string{"40", "200"},
+		ResizeQuality:        80,
+		ResizeCacheSize:      1 << 20,
+		ResizeCacheDirectory: cacheDirectory,
+	}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		path       string
+		wantStatus int
+		wantType   string
+		wantWidth  int
+		wantHeight int
+	}{
+		"resized": {
+			"/photo.png?w=40",
+			http.StatusOK,
+			"image/png",
+			40,
+			20,
+		},
+		"converted": {
+			"/photo.png?w=40&fmt=jpg",
+			http.StatusOK,
+			"image/jpeg",
+			40,
+			20,
+		},
+		"never enlarged": {
+			"/photo.png?w=200",
+			http.StatusOK,
+			"image/png",
+			100,
+			50,
+		},
+		"width not allowed": {
+			"/photo.png?w=41",
+			http.StatusBadRequest,
+			"",
+			0,
+			0,
+		},
+		"webp not encoded": {
+			"/photo.png?w=40&fmt=webp",
+			http.StatusBadRequest,
+			"",
+			0,
+			0,
+		},
+		"format not supported": {
+			"/photo.png?w=40&fmt=bmp",
+			http.StatusBadRequest,
+			"",
+			0,
+			0,
+		},
+		"original": {
+			"/photo.png",
+			http.StatusOK,
+			"image/png",
+			100,
+			50,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			writer := httptest.NewRecorder()
+			instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if writer.Code != tc.wantStatus {
+				t.Errorf("Handler() = %d, want %d", writer.Code, tc.wantStatus)
+			}
+
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+
+			if got := writer.Header().Get("Content-Type"); got != tc.wantType {
+				t.Errorf("Handler() Content-Type = `%s`, want `%s`", got, tc.wantType)
+			}
+
+			if len(writer.Header().Get("Etag")) == 0 || len(writer.Header().Get(cacheControlHeader)) == 0 {
+				t.Errorf("Handler() headers = %v, want Etag and Cache-Control", writer.Header())
+			}
+
+			config, _, err := image.DecodeConfig(writer.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if config.Width != tc.wantWidth || config.Height != tc.wantHeight {
+				t.Errorf("Handler() = %dx%d, want %dx%d", config.Width, config.Height, tc.wantWidth, tc.wantHeight)
+			}
+		})
+	}
+
+	first := httptest.NewRecorder()
+	instance.Handler().ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/photo.png?w=40", nil))
+
+	request := httptest.NewRequest(http.MethodGet, "/photo.png?w=40", nil)
+	request.Header.Set("If-None-Match", first.Header().Get("Etag"))
+
+	writer := httptest.NewRecorder()
+	instance.Handler().ServeHTTP(writer, request)
+
+	if writer.Code != http.StatusNotModified {
+		t.Errorf("Handler() with Etag = %d, want %d", writer.Code, http.StatusNotModified)
+	}
+
+	if stored, err := filepath.Glob(filepath.Join(cacheDirectory, "*.*")); err != nil || len(stored) != 3 {
+		t.Errorf("Handler() stored %v, want 3 variants on disk", stored)
+	}
+}
@@ -0,0 +1,185 @@
+package viws
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ViBiOh/httputils/v4/pkg/hash"
+	"github.com/ViBiOh/viws/pkg/accesslog"
+)
+
+const (
+	sitemapPath = "/sitemap.xml"
+	robotsPath  = "/robots.txt"
+
+	productionEnv = "production"
+
+	// maxSitemapURLs is the limit of a single sitemap file.
+	maxSitemapURLs = 50_000
+)
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type generatedSitemap struct {
+	generatedAt time.Time
+	content     []byte
+}
+
+// seo generates sitemap.xml and robots.txt for sites that don't provide them. The sitemap is generated at startup and
+// walked again off the request path at every refresh interval, requests only reading the last one.
+type seo struct {
+	sitemap    atomic.Pointer[generatedSitemap]
+	baseURL    string
+	refresh    time.Duration
+	production bool
+}
+
+func newSeo(config *Config) *seo {
+	if len(config.SeoBaseURL) == 0 {
+		return nil
+	}
+
+	return &seo{
+		baseURL:    strings.TrimSuffix(config.SeoBaseURL, "/"),
+		refresh:    config.SeoRefresh,
+		production: os.Getenv(config.SeoEnv) == productionEnv,
+	}
+}
+
+func (s *seo) handles(path string) bool {
+	return s != nil && (path == sitemapPath || path == robotsPath)
+}
+
+func (s *seo) robots(preview bool) []byte {
+	if !s.production || preview {
+		return []byte("User-agent: *\nDisallow: /\n")
+	}
+
+	return fmt.Appendf(nil, "User-agent: *\nAllow: /\n\nSitemap: %s%s\n", s.baseURL, sitemapPath)
+}
+
+// update generates the sitemap of the directory, the previous one being kept on error.
+func (s *seo) update(ctx context.Context, files storage, directory string) {
+	content, err := s.generateSitemap(files, directory)
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelError, "generate sitemap, keeping previous one", slog.String("dir", directory), slog.Any("error", err))
+		return
+	}
+
+	s.sitemap.Store(&generatedSitemap{generatedAt: time.Now(), content: content})
+}
+
+// generateSitemap lists HTML pages of the directory, an index.html being listed as its directory.
+func (s *seo) generateSitemap(files storage, directory string) ([]byte, error) {
+	urlSet := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+
+	err := files.Walk(directory, func(name string, info fs.FileInfo) error {
+		relative, err := filepath.Rel(directory, name)
+		if err != nil {
+			return err
+		}
+
+		relative = filepath.ToSlash(relative)
+
+		if !isHTML(relative) || filepath.Base(relative) == notFoundFilename || hidden(relative) {
+			return nil
+		}
+
+		page := "/" + relative
+		if filepath.Base(relative) == indexFilename {
+			page = strings.TrimSuffix(page, indexFilename)
+		}
+
+		urlSet.URLs = append(urlSet.URLs, sitemapURL{
+			Loc:     s.baseURL + page,
+			LastMod: info.ModTime().UTC().Format(time.RFC3339),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk: %w", err)
+	}
+
+	slices.SortFunc(urlSet.URLs, func(a, b sitemapURL) int {
+		return strings.Compare(a.Loc, b.Loc)
+	})
+
+	if len(urlSet.URLs) > maxSitemapURLs {
+		slog.Warn("Too many pages for a sitemap, truncating", "dir", directory, "count", len(urlSet.URLs))
+		urlSet.URLs = urlSet.URLs[:maxSitemapURLs]
+	}
+
+	output := bytes.NewBufferString(xml.Header)
+
+	encoder := xml.NewEncoder(output)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(urlSet); err != nil {
+		return nil, fmt.Errorf("encode: %w", err)
+	}
+
+	return output.Bytes(), nil
+}
+
+// hidden checks if a part of the path starts with a dot, e.g. .well-known.
+func hidden(relative string) bool {
+	for part := range strings.SplitSeq(relative, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a App) serveSeo(w http.ResponseWriter, r *http.Request) {
+	accesslog.SetFile(r.Context(), r.URL.Path)
+
+	var content []byte
+	var modTime time.Time
+
+	if r.URL.Path == robotsPath {
+		content = a.seo.robots(a.previewing)
+	} else {
+		// Previews are never indexed, and a newly activated release is served the previous sitemap until the next refresh
+		sitemap := a.seo.sitemap.Load()
+		if sitemap == nil || a.previewing {
+			a.serveNotFound(w, r)
+			return
+		}
+
+		content, modTime = sitemap.content, sitemap.generatedAt
+	}
+
+	etag := weakETag(hash.String(string(content)))
+
+	w.Header().Set(cacheControlHeader, noCacheValue)
+	w.Header().Set("Etag", etag)
+
+	if checkConditions(w, r, etag, modTime) {
+		return
+	}
+
+	http.ServeContent(w, r, r.URL.Path, modTime, bytes.NewReader(content))
+}
@@ -0,0 +1,163 @@
+package viws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeSeo(t *testing.T) {
+	directory := t.TempDir()
+	provided := t.TempDir()
+
+	for _, name := range []string{"index.html", "about/index.html", "blog/post.html", "404.html", ".well-known/hidden.html", "style.css"} {
+		if err := os.MkdirAll(filepath.Join(directory, filepath.Dir(name)), 0o700); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	modTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(directory, "blog/post.html"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(provided, "robots.txt"), []byte("User-agent: custom\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{Directory: directory, SeoBaseURL: "https://example.com/", SeoEnv: "VIWS_TEST_ENV", SeoRefresh: time.Minute}
+
+	t.Setenv("VIWS_TEST_ENV", "production")
+
+	production, err := New(&config, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("VIWS_TEST_ENV", "staging")
+
+	staging, err := New(&config, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Directory = provided
+
+	site, err := New(&config, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		instance App
+		path     string
+		want     []string
+		wantNot  []string
+		wantType string
+	}{
+		"sitemap": {
+			production,
+			"/sitemap.xml",
+			[]string{
+				"<loc>https://example.com/</loc>",
+				"<loc>https://example.com/about/</loc>",
+				"<url>\n    <loc>https://example.com/blog/post.html</loc>\n    <lastmod>2026-01-02T03:04:05Z</lastmod>\n  </url>",
+			},
+			[]string{"404.html", "hidden.html", "style.css"},
+			"text/xml; charset=utf-8",
+		},
+		"production robots": {
+			production,
+			"/robots.txt",
+			[]string{"Allow: /", "Sitemap: https://example.com/sitemap.xml"},
+			[]string{"Disallow"},
+			"text/plain; charset=utf-8",
+		},
+		"staging robots": {
+			staging,
+			"/robots.txt",
+			[]string{"Disallow: /"},
+			[]string{"Sitemap"},
+			"text/plain; charset=utf-8",
+		},
+		"provided robots": {
+			site,
+			"/robots.txt",
+			[]string{"User-agent: custom"},
+			[]string{"Disallow"},
+			"text/plain; charset=utf-8",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			writer := httptest.NewRecorder()
+			tc.instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if writer.Code != http.StatusOK {
+				t.Errorf("Handler() = %d, want %d", writer.Code, http.StatusOK)
+			}
+
+			if got := writer.Header().Get("Content-Type"); got != tc.wantType {
+				t.Errorf("Handler() Content-Type = `%s`, want `%s`", got, tc.wantType)
+			}
+
+			body := writer.Body.String()
+
+			for _, want := range tc.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("Handler() = `%s`, want `%s`", body, want)
+				}
+			}
+
+			for _, wantNot := range tc.wantNot {
+				if strings.Contains(body, wantNot) {
+					t.Errorf("Handler() = `%s`, want no `%s`", body, wantNot)
+				}
+			}
+		})
+	}
+}
+
+func TestSitemapRefresh(t *testing.T) {
+	directory := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(directory, "index.html"), []byte("index"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := New(&Config{Directory: directory, SeoBaseURL: "https://example.com", SeoRefresh: time.Minute}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(directory, "about.html"), []byte("about"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sitemap := func() string {
+		writer := httptest.NewRecorder()
+		instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, sitemapPath, nil))
+
+		return writer.Body.String()
+	}
+
+	if body := sitemap(); strings.Contains(body, "about.html") {
+		t.Errorf("sitemap before refresh = `%s`, want no `about.html`", body)
+	}
+
+	instance.refreshSitemap(context.Background())
+
+	if body := sitemap(); !strings.Contains(body, "<loc>https://example.com/about.html</loc>") {
+		t.Errorf("sitemap after refresh = `%s`, want `about.html`", body)
+	}
+}
@@ -2,19 +2,25 @@ package viws
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"maps"
 	"net/http"
-	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/ViBiOh/flags"
-	"github.com/ViBiOh/httputils/v4/pkg/hash"
 	"github.com/ViBiOh/httputils/v4/pkg/httperror"
+	"github.com/ViBiOh/viws/pkg/accesslog"
+	"github.com/ViBiOh/viws/pkg/compress"
+	"github.com/ViBiOh/viws/pkg/dev"
+	"github.com/ViBiOh/viws/pkg/metrics"
+	"github.com/ViBiOh/viws/pkg/release"
 )
 
 const (
@@ -22,6 +28,7 @@ const (
 	notFoundFilename   = "404.html"
 	cacheControlHeader = "Cache-Control"
 	noCacheValue       = "no-cache"
+	noStoreValue       = "no-store"
 )
 
 var bufferPool = sync.Pool{
@@ -31,101 +38,361 @@ var bufferPool = sync.Pool{
 }
 
 type App struct {
-	headers   http.Header
-	directory string
-	spa       bool
+	files           storage
+	index           *fileIndex
+	releases        *release.Service
+	preview         *preview
+	metrics         *appMetrics
+	dev             *dev.Service
+	compress        *compress.Service
+	resizer         *resizer
+	seo             *seo
+	headers         http.Header
+	inlineHashes    *fileCache[inlineHashes]
+	preloadCache    *fileCache[[]string]
+	preloadManifest map[string][]string
+	readyFiles      []string
+	readyManifest   string
+	directory       string
+	spa             bool
+	nonce           bool
+	imageVariants   bool
+	previewing      bool
 }
 
 type Config struct {
-	Directory string
-	Headers   []string
-	Spa       bool
+	Directory            string
+	Archive              string
+	PreviewHost          string
+	PreviewDirectory     string
+	PreloadManifest      string
+	Headers              []string
+	Spa                  bool
+	Nonce                bool
+	CspHash              bool
+	EarlyHints           bool
+	ImageVariants        bool
+	ResizeWidths         []string
+	ResizeCacheDirectory string
+	ResizeCacheSize      uint
+	ResizeDiskSize       uint
+	ResizeConcurrency    uint
+	ResizeQuality        int
+	SeoBaseURL           string
+	SeoEnv               string
+	SeoRefresh           time.Duration
+	ReadyFiles           []string
+	ReadyManifest        string
+	Index                bool
+	IndexRefresh         time.Duration
 }
 
 func Flags(fs *flag.FlagSet, prefix string, overrides ...flags.Override) *Config {
 	var config Config
 
 	flags.New("Directory", "Directory to serve").Prefix(prefix).DocPrefix("viws").StringVar(fs, &config.Directory, "/www/", overrides)
+	flags.New("Archive", "Archive to serve instead of directory, .zip, .tar or .tar.gz").Prefix(prefix).DocPrefix("viws").StringVar(fs, &config.Archive, "", overrides)
+	flags.New("PreviewHost", "Host pattern of preview deployments, {sub} being the preview name, e.g. {sub}.preview.example.com").Prefix(prefix).DocPrefix("viws").StringVar(fs, &config.PreviewHost, "", overrides)
+	flags.New("PreviewDirectory", "Directory of preview deployments, {sub} being replaced by the preview name").Prefix(prefix).DocPrefix("viws").StringVar(fs, &config.PreviewDirectory, "/previews/{sub}", overrides)
 	flags.New("Header", "Custom header e.g. content-language:fr").Prefix(prefix).DocPrefix("viws").StringSliceVar(fs, &config.Headers, nil, overrides)
 	flags.New("Spa", "Indicate Single Page Application mode").Prefix(prefix).DocPrefix("viws").BoolVar(fs, &config.Spa, false, overrides)
+	flags.New("Nonce", "Inject a Content-Security-Policy nonce in HTML files, disabling their cache").Prefix(prefix).DocPrefix("viws").BoolVar(fs, &config.Nonce, false, overrides)
+	flags.New("EarlyHints", "Send 103 Early Hints and Link headers of preloaded assets").Prefix(prefix).DocPrefix("viws").BoolVar(fs, &config.EarlyHints, false, overrides)
+	flags.New("PreloadManifest", "JSON manifest of assets to preload per file, instead of parsing <link rel=preload> in HTML").Prefix(prefix).DocPrefix("viws").StringVar(fs, &config.PreloadManifest, "", overrides)
+	flags.New("ImageVariants", "Serve the .avif or .webp sibling of JPEG, PNG and GIF images to clients accepting it").Prefix(prefix).DocPrefix("viws").BoolVar(fs, &config.ImageVariants, false, overrides)
+	flags.New("ResizeWidths", "Widths allowed to resize images with ?w=, e.g. 320,640,1280, empty to disable").Prefix(prefix).DocPrefix("viws").StringSliceVar(fs, &config.ResizeWidths, nil, overrides)
+	flags.New("ResizeQuality", "JPEG quality of resized images, from 1 to 100").Prefix(prefix).DocPrefix("viws").IntVar(fs, &config.ResizeQuality, 85, overrides)
+	flags.New("ResizeCacheSize", "Maximum memory in bytes of resized images kept in cache").Prefix(prefix).DocPrefix("viws").UintVar(fs, &config.ResizeCacheSize, 64<<20, overrides)
+	flags.New("ResizeCacheDirectory", "Directory where resized images are also stored, to survive restarts").Prefix(prefix).DocPrefix("viws").StringVar(fs, &config.ResizeCacheDirectory, "", overrides)
+	flags.New("ResizeDiskSize", "Maximum disk usage in bytes of resized images stored in directory, least recently used being removed first, 0 for no limit").Prefix(prefix).DocPrefix("viws").UintVar(fs, &config.ResizeDiskSize, 1<<30, overrides)
+	flags.New("ResizeConcurrency", "Maximum number of images resized at once, 0 for the number of CPUs").Prefix(prefix).DocPrefix("viws").UintVar(fs, &config.ResizeConcurrency, 0, overrides)
+	flags.New("SeoBaseURL", "Base URL of pages, generating sitemap.xml and robots.txt when the site doesn't provide them, e.g. https://example.com").Prefix(prefix).DocPrefix("viws").StringVar(fs, &config.SeoBaseURL, "", overrides)
+	flags.New("SeoEnv", "Environment variable of the environment, generated robots.txt disallowing all unless it's production").Prefix(prefix).DocPrefix("viws").StringVar(fs, &config.SeoEnv, "ENV", overrides)
+	flags.New("SeoRefresh", "Interval to walk the directory again for the generated sitemap.xml").Prefix(prefix).DocPrefix("viws").DurationVar(fs, &config.SeoRefresh, time.Minute, overrides)
+	flags.New("ReadyFiles", "Files that must be readable in the served directory for readiness, e.g. index.html,404.html").Prefix(prefix).DocPrefix("viws").StringSliceVar(fs, &config.ReadyFiles, nil, overrides)
+	flags.New("ReadyManifest", "JSON manifest in the served directory, of files that must all exist for readiness").Prefix(prefix).DocPrefix("viws").StringVar(fs, &config.ReadyManifest, "", overrides)
+	flags.New("Index", "Index files of the directory in memory at startup, resolving requests without disk access").Prefix(prefix).DocPrefix("viws").BoolVar(fs, &config.Index, false, overrides)
+	flags.New("IndexRefresh", "Interval to rebuild the file index, also rebuilt on SIGHUP, 0 to disable").Prefix(prefix).DocPrefix("viws").DurationVar(fs, &config.IndexRefresh, time.Minute, overrides)
+	flags.New("CspHash", "Add hashes of inline scripts and styles to Content-Security-Policy of HTML files").Prefix(prefix).DocPrefix("viws").BoolVar(fs, &config.CspHash, false, overrides)
 
 	return &config
 }
 
-func New(config *Config) App {
+func New(config *Config, releases *release.Service, registry *metrics.Registry, devService *dev.Service, compressService *compress.Service) (App, error) {
 	a := App{
-		spa:       config.Spa,
-		directory: config.Directory,
-		headers:   http.Header{},
+		spa:           config.Spa,
+		nonce:         config.Nonce,
+		imageVariants: config.ImageVariants,
+		directory:     config.Directory,
+		headers:       http.Header{},
+		releases:      releases,
+		metrics:       newAppMetrics(registry),
+		dev:           devService,
+		compress:      compressService,
+		readyFiles:    config.ReadyFiles,
+		readyManifest: config.ReadyManifest,
+	}
+
+	if len(config.Archive) != 0 && releases != nil {
+		return a, errors.New("archive and releases are mutually exclusive")
+	}
+
+	if len(config.PreviewHost) != 0 && len(config.Archive) != 0 {
+		return a, errors.New("archive and previews are mutually exclusive")
+	}
+
+	preview, err := newPreview(config.PreviewHost, config.PreviewDirectory)
+	if err != nil {
+		return a, fmt.Errorf("preview: %w", err)
+	}
+
+	a.preview = preview
+
+	resizer, err := newResizer(config, registry)
+	if err != nil {
+		return a, fmt.Errorf("resize: %w", err)
+	}
+
+	a.resizer = resizer
+	a.seo = newSeo(config)
+
+	if len(config.Archive) != 0 {
+		files, err := openArchive(config.Archive)
+		if err != nil {
+			return a, fmt.Errorf("archive `%s`: %w", config.Archive, err)
+		}
+
+		a.files = files
+		a.directory = "/"
+	}
+
+	if a.index = newFileIndex(config); a.index != nil {
+		a.files = a.index
+
+		if root := a.indexRoot(); len(root) != 0 {
+			a.index.update(context.Background(), root)
+		}
 	}
 
 	logger := slog.With("dir", a.directory)
 
+	if len(config.Archive) != 0 {
+		logger = slog.With("archive", config.Archive)
+	} else if releases != nil {
+		logger = slog.With("releases", true)
+	}
+
 	logger.Info("Serving file")
 
+	if a.index != nil {
+		logger.Info("File index enabled", "refresh", a.index.refresh)
+	}
+
 	if a.spa {
 		logger.Info("Single Page Application mode enabled")
 	}
 
+	if a.dev != nil {
+		logger.Warn("Development mode enabled, caching disabled")
+	}
+
+	if a.imageVariants {
+		logger.Info("AVIF and WebP image variants enabled")
+	}
+
+	if a.resizer != nil {
+		logger.Info("Image resizing enabled", "widths", config.ResizeWidths)
+	}
+
+	if a.seo != nil {
+		logger.Info("Sitemap and robots generation enabled", "baseURL", a.seo.baseURL, "production", a.seo.production)
+
+		if root := a.indexRoot(); len(root) != 0 {
+			a.seo.update(context.Background(), a.storage(), root)
+		}
+	}
+
+	if a.nonce {
+		logger.Info("Content-Security-Policy nonce enabled")
+	} else if config.CspHash {
+		a.inlineHashes = newFileCache(computeInlineHashes)
+		logger.Info("Content-Security-Policy inline hashes enabled")
+	}
+
+	if config.EarlyHints {
+		if len(config.PreloadManifest) != 0 {
+			manifest, err := loadPreloadManifest(config.PreloadManifest)
+			if err != nil {
+				logger.Error("load preload manifest, fallback to HTML parsing", "manifest", config.PreloadManifest, "error", err)
+			} else {
+				a.preloadManifest = manifest
+			}
+		}
+
+		if a.preloadManifest == nil {
+			a.preloadCache = newFileCache(parsePreloadLinks)
+		}
+
+		logger.Info("Early Hints enabled")
+	}
+
 	if len(config.Headers) != 0 {
 		for _, header := range config.Headers {
-			if parts := strings.SplitN(header, ":", 2); len(parts) != 2 || strings.Contains(parts[0], " ") {
+			if name, value, ok := parseHeader(header); !ok {
 				logger.Warn("header has wrong format", "header", header)
 			} else {
-				a.headers.Add(parts[0], parts[1])
+				a.headers.Add(name, value)
 			}
 		}
 	}
 
-	return a
+	return a, nil
+}
+
+// parseHeader splits a `name:value` custom header, the name being non empty and without space.
+func parseHeader(header string) (string, string, bool) {
+	name, value, ok := strings.Cut(header, ":")
+	if !ok || len(name) == 0 || strings.Contains(name, " ") {
+		return "", "", false
+	}
+
+	return name, value, true
 }
 
 func (a App) Handler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return a.metrics.instrument(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r) {
+			return
+		}
+
+		if r.Method == http.MethodHead {
+			w = headResponseWriter{w}
+		}
+
+		a, ok := a.forRequest(w, r)
+		if !ok {
+			return
+		}
+
 		if strings.Contains(r.URL.Path, "..") {
 			httperror.BadRequest(r.Context(), w, fmt.Errorf("path with dots are not allowed: `%s`", r.URL.Path))
 			return
 		}
 
-		if filename, info, err := getFileToServe(a.directory, r.URL.Path); err == nil {
-			a.serveFile(w, r, filename, hash.Hash(info), info.ModTime())
-			return
+		if filename, info, err := getFileToServe(a.storage(), a.directory, r.URL.Path); err == nil {
+			if a.resizer.wantsResize(r, filename) {
+				a.serveResized(w, r, filename, info)
+				return
+			}
+
+			filename, info = a.imageVariant(w, r, filename, info)
+			if a.serveFile(w, r, filename, fileHash(info), info.ModTime()) {
+				return
+			}
 		}
 
-		if r.Method == http.MethodHead {
-			w.WriteHeader(http.StatusNotFound)
+		if a.seo.handles(r.URL.Path) {
+			a.serveSeo(w, r)
 			return
 		}
 
 		if a.spa {
-			if filename, info, err := getFileToServe(a.directory, indexFilename); err == nil {
+			if filename, info, err := getFileToServe(a.storage(), a.directory, indexFilename); err == nil {
 				w.Header().Add(cacheControlHeader, noCacheValue)
-				a.serveFile(w, r, filename, hash.Hash(info), info.ModTime())
-				return
+
+				if a.serveFile(w, r, filename, fileHash(info), info.ModTime()) {
+					a.metrics.spaFallback()
+					return
+				}
 			}
 		}
 
-		a.serveNotFound(r.Context(), w)
-	})
+		a.serveNotFound(w, r)
+	}))
+}
+
+// forRequest resolves the directory to serve: the preview matching the host, or a snapshot of the active release so
+// that every file of a request comes from the same one. It writes the response when it can't be served.
+func (a App) forRequest(w http.ResponseWriter, r *http.Request) (App, bool) {
+	if directory, matched, valid := a.preview.match(r.Host); matched {
+		w.Header().Set(robotsTagHeader, "noindex")
+
+		if !valid {
+			httperror.NotFound(r.Context(), w, nil)
+			return a, false
+		}
+
+		a.directory = directory
+		a.previewing = true
+
+		return a, true
+	}
+
+	if a.releases == nil {
+		return a, true
+	}
+
+	active := a.releases.Active()
+	if len(active.ID) == 0 {
+		httperror.InternalServerError(r.Context(), w, errNoActiveRelease)
+		return a, false
+	}
+
+	a.directory = active.Directory
+	w.Header().Set(release.Header, active.ID)
+
+	return a, true
 }
 
-func (a App) serveFile(w http.ResponseWriter, r *http.Request, filepath, hash string, modTime time.Time) {
+// serveFile returns false without writing the response when the file doesn't exist anymore, e.g. deleted since the
+// index was built, so that the request falls back as a missing file.
+func (a App) serveFile(w http.ResponseWriter, r *http.Request, filepath, hash string, modTime time.Time) bool {
+	accesslog.SetFile(r.Context(), filepath)
 	a.addCustomHeaders(w)
 
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusNoContent)
-		return
+	if a.dynamicHTML() && isHTML(filepath) {
+		a.sendEarlyHints(w, r, filepath, modTime)
+
+		if !a.serveDynamic(w, r, http.StatusOK, filepath) {
+			resetFileHeaders(w)
+			return false
+		}
+
+		return true
 	}
 
-	etag, ok := etagMatch(w, r, hash)
-	if ok {
-		return
+	// A 304 updates the cached headers, so they are all set before checking conditions
+	a.addInlineHashes(r.Context(), w, filepath, modTime)
+	hinted := a.addPreloadLinks(r.Context(), w, filepath, modTime)
+
+	var etag string
+
+	if a.dev == nil {
+		etag = weakETag(hash)
+
+		setCacheHeader(w, r)
+		w.Header().Add("Etag", etag)
+		a.addEncodingVary(w, filepath)
+
+		if checkConditions(w, r, etag, modTime) {
+			return true
+		}
+	}
+
+	// Hints are sent before opening the file, so that slow storage doesn't delay them
+	if hinted {
+		flushEarlyHints(w, r)
 	}
 
-	file, err := os.OpenFile(filepath, os.O_RDONLY, 0o600)
+	file, err := a.storage().Open(filepath)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			resetFileHeaders(w)
+			return false
+		}
+
 		httperror.InternalServerError(r.Context(), w, err)
-		return
+		return true
 	}
 
 	defer func() {
@@ -134,9 +401,28 @@ func (a App) serveFile(w http.ResponseWriter, r *http.Request, filepath, hash st
 		}
 	}()
 
-	setCacheHeader(w, r)
-	w.Header().Add("Etag", etag)
-	http.ServeContent(w, r, filepath, modTime, file)
+	if a.dev != nil {
+		w.Header().Set(cacheControlHeader, noStoreValue)
+		http.ServeContent(w, r, filepath, time.Time{}, file)
+
+		return true
+	}
+
+	if !a.serveCompressed(w, r, filepath, etag, modTime, file) {
+		http.ServeContent(w, r, filepath, modTime, file)
+	}
+
+	return true
+}
+
+// resetFileHeaders removes headers of a file that can't be served, before falling back.
+func resetFileHeaders(w http.ResponseWriter) {
+	header := w.Header()
+
+	header.Del(cacheControlHeader)
+	header.Del("Etag")
+	header.Del("Vary")
+	header.Del(linkHeader)
 }
 
 func (a App) addCustomHeaders(w http.ResponseWriter) {
@@ -152,14 +438,3 @@ func setCacheHeader(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
-
-func etagMatch(w http.ResponseWriter, r *http.Request, hash string) (etag string, match bool) {
-	etag = fmt.Sprintf(`W/"%s"`, hash)
-
-	if r.Header.Get("If-None-Match") == etag {
-		w.WriteHeader(http.StatusNotModified)
-		match = true
-	}
-
-	return etag, match
-}
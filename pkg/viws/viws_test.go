@@ -5,12 +5,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/ViBiOh/httputils/v4/pkg/hash"
 	"github.com/ViBiOh/httputils/v4/pkg/request"
+	"github.com/ViBiOh/viws/pkg/dev"
+	"github.com/ViBiOh/viws/pkg/release"
 )
 
 var exampleDir = "../../example/"
@@ -20,7 +23,7 @@ func TestFlags(t *testing.T) {
 		want string
 	}{
 		"simple": {
-			"Usage of simple:\n  -directory string\n    \t[viws] Directory to serve ${SIMPLE_DIRECTORY} (default \"/www/\")\n  -header string slice\n    \t[viws] Custom header e.g. content-language:fr ${SIMPLE_HEADER}, as a string slice, environment variable separated by \",\"\n  -spa\n    \t[viws] Indicate Single Page Application mode ${SIMPLE_SPA}\n",
+			"Usage of simple:\n  -archive string\n    \t[viws] Archive to serve instead of directory, .zip, .tar or .tar.gz ${SIMPLE_ARCHIVE}\n  -cspHash\n    \t[viws] Add hashes of inline scripts and styles to Content-Security-Policy of HTML files ${SIMPLE_CSP_HASH}\n  -directory string\n    \t[viws] Directory to serve ${SIMPLE_DIRECTORY} (default \"/www/\")\n  -earlyHints\n    \t[viws] Send 103 Early Hints and Link headers of preloaded assets ${SIMPLE_EARLY_HINTS}\n  -header string slice\n    \t[viws] Custom header e.g. content-language:fr ${SIMPLE_HEADER}, as a string slice, environment variable separated by \",\"\n  -imageVariants\n    \t[viws] Serve the .avif or .webp sibling of JPEG, PNG and GIF images to clients accepting it ${SIMPLE_IMAGE_VARIANTS}\n  -index\n    \t[viws] Index files of the directory in memory at startup, resolving requests without disk access ${SIMPLE_INDEX}\n  -indexRefresh duration\n    \t[viws] Interval to rebuild the file index, also rebuilt on SIGHUP, 0 to disable ${SIMPLE_INDEX_REFRESH} (default 1m0s)\n  -nonce\n    \t[viws] Inject a Content-Security-Policy nonce in HTML files, disabling their cache ${SIMPLE_NONCE}\n  -preloadManifest string\n    \t[viws] JSON manifest of assets to preload per file, instead of parsing <link rel=preload> in HTML ${SIMPLE_PRELOAD_MANIFEST}\n  -previewDirectory string\n    \t[viws] Directory of preview deployments, {sub} being replaced by the preview name ${SIMPLE_PREVIEW_DIRECTORY} (default \"/previews/{sub}\")\n  -previewHost string\n    \t[viws] Host pattern of preview deployments, {sub} being the preview name, e.g. {sub}.preview.example.com ${SIMPLE_PREVIEW_HOST}\n  -readyFiles string slice\n    \t[viws] Files that must be readable in the served directory for readiness, e.g. index.html,404.html ${SIMPLE_READY_FILES}, as a string slice, environment variable separated by \",\"\n  -readyManifest string\n    \t[viws] JSON manifest in the served directory, of files that must all exist for readiness ${SIMPLE_READY_MANIFEST}\n  -resizeCacheDirectory string\n    \t[viws] Directory where resized images are also stored, to survive restarts ${SIMPLE_RESIZE_CACHE_DIRECTORY}\n  -resizeCacheSize uint\n    \t[viws] Maximum memory in bytes of resized images kept in cache ${SIMPLE_RESIZE_CACHE_SIZE} (default 67108864)\n  -resizeConcurrency uint\n    \t[viws] Maximum number of images resized at once, 0 for the number of CPUs ${SIMPLE_RESIZE_CONCURRENCY}\n  -resizeDiskSize uint\n    \t[viws] Maximum disk usage in bytes of resized images stored in directory, least recently used being removed first, 0 for no limit ${SIMPLE_RESIZE_DISK_SIZE} (default 1073741824)\n  -resizeQuality int\n    \t[viws] JPEG quality of resized images, from 1 to 100 ${SIMPLE_RESIZE_QUALITY} (default 85)\n  -resizeWidths string slice\n    \t[viws] Widths allowed to resize images with ?w=, e.g. 320,640,1280, empty to disable ${SIMPLE_RESIZE_WIDTHS}, as a string slice, environment variable separated by \",\"\n  -seoBaseURL string\n    \t[viws] Base URL of pages, generating sitemap.xml and robots.txt when the site doesn't provide them, e.g. https://example.com ${SIMPLE_SEO_BASE_URL}\n  -seoEnv string\n    \t[viws] Environment variable of the environment, generated robots.txt disallowing all unless it's production ${SIMPLE_SEO_ENV} (default \"ENV\")\n  -seoRefresh duration\n    \t[viws] Interval to walk the directory again for the generated sitemap.xml ${SIMPLE_SEO_REFRESH} (default 1m0s)\n  -spa\n    \t[viws] Indicate Single Page Application mode ${SIMPLE_SPA}\n",
 		},
 	}
 
@@ -96,7 +99,7 @@ func TestNew(t *testing.T) {
 
 	for intention, tc := range cases {
 		t.Run(intention, func(t *testing.T) {
-			if result := New(tc.input); !reflect.DeepEqual(result, tc.want) {
+			if result, _ := New(tc.input, nil, nil, nil, nil); !reflect.DeepEqual(result, tc.want) {
 				t.Errorf("New() = %+v, want %+v", result, tc.want)
 			}
 		})
@@ -117,14 +120,40 @@ func TestHandler(t *testing.T) {
 			},
 			httptest.NewRequest(http.MethodHead, "/", nil),
 			"",
-			http.StatusNoContent,
-			nil,
+			http.StatusOK,
+			http.Header{
+				cacheControlHeader: {noCacheValue},
+				"Content-Type":     {"text/html; charset=utf-8"},
+				"Content-Length":   {"213"},
+			},
+		},
+		"options": {
+			App{
+				directory: exampleDir,
+			},
+			httptest.NewRequest(http.MethodOptions, "/index.js", nil),
+			"",
+			http.StatusOK,
+			http.Header{
+				"Allow": {"GET, HEAD, OPTIONS"},
+			},
+		},
+		"method not allowed": {
+			App{
+				directory: exampleDir,
+			},
+			httptest.NewRequest(http.MethodPost, "/index.js", nil),
+			"",
+			http.StatusMethodNotAllowed,
+			http.Header{
+				"Allow": {"GET, HEAD, OPTIONS"},
+			},
 		},
 		"path with dots": {
 			App{
 				directory: exampleDir,
 			},
-			httptest.NewRequest(http.MethodHead, "/../index.html", nil),
+			httptest.NewRequest(http.MethodGet, "/../index.html", nil),
 			"path with dots are not allowed: `/../index.html`\n",
 			http.StatusBadRequest,
 			nil,
@@ -175,7 +204,32 @@ func TestHandler(t *testing.T) {
 			"",
 			http.StatusNotFound,
 			http.Header{
-				cacheControlHeader: {""},
+				cacheControlHeader: {noCacheValue},
+			},
+		},
+		"head not found with file": {
+			App{
+				directory: "../../example/404/",
+			},
+			httptest.NewRequest(http.MethodHead, "/nowhere", nil),
+			"",
+			http.StatusNotFound,
+			http.Header{
+				cacheControlHeader: {noCacheValue},
+				"Content-Type":     {"text/html; charset=utf-8"},
+			},
+		},
+		"head not found with spa": {
+			App{
+				directory: exampleDir,
+				spa:       true,
+			},
+			httptest.NewRequest(http.MethodHead, "/user/1234", nil),
+			"",
+			http.StatusOK,
+			http.Header{
+				cacheControlHeader: {noCacheValue},
+				"Content-Length":   {"213"},
 			},
 		},
 		"get not found": {
@@ -299,3 +353,238 @@ func BenchmarkServeFile(b *testing.B) {
 		instance.serveFile(recorder, req, "../../example/404/index.html", hash, info.ModTime())
 	}
 }
+
+func TestHandlerRelease(t *testing.T) {
+	root := writeSite(t, map[string]string{
+		"releases/v1/" + indexFilename: "v1",
+		"releases/v2/" + indexFilename: "v2",
+	})
+
+	releases, err := release.New(&release.Config{Directory: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, _ := New(&Config{}, releases, nil, nil, nil)
+
+	writer := httptest.NewRecorder()
+	instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if result := writer.Code; result != http.StatusInternalServerError {
+		t.Errorf("Status %d, want %d", result, http.StatusInternalServerError)
+	}
+
+	for _, id := range []string{"v1", "v2"} {
+		if err := releases.Activate(id); err != nil {
+			t.Fatal(err)
+		}
+
+		writer := httptest.NewRecorder()
+		instance.Handler().ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if result := writer.Body.String(); result != id {
+			t.Errorf("Body `%s`, want `%s`", result, id)
+		}
+
+		if result := writer.Header().Get(release.Header); result != id {
+			t.Errorf("%s = `%s`, want `%s`", release.Header, result, id)
+		}
+	}
+}
+
+func TestHandlerPreview(t *testing.T) {
+	root := writeSite(t, map[string]string{
+		"main/" + indexFilename:            "main",
+		"previews/feat-1/" + indexFilename: "feat-1",
+	})
+
+	instance, err := New(&Config{
+		Directory:        filepath.Join(root, "main"),
+		PreviewHost:      "{sub}.preview.example.com",
+		PreviewDirectory: filepath.Join(root, "previews", "{sub}"),
+		Spa:              true,
+	}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		host       string
+		path       string
+		wantStatus int
+		wantBody   string
+		wantRobots string
+	}{
+		"main": {
+			"www.example.com",
+			"/",
+			http.StatusOK,
+			"main",
+			"",
+		},
+		"preview": {
+			"feat-1.preview.example.com:8080",
+			"/",
+			http.StatusOK,
+			"feat-1",
+			"noindex",
+		},
+		"preview spa": {
+			"FEAT-1.preview.example.com",
+			"/deep/link",
+			http.StatusOK,
+			"feat-1",
+			"noindex",
+		},
+		"unknown preview": {
+			"other.preview.example.com",
+			"/",
+			http.StatusNotFound,
+			"",
+			"noindex",
+		},
+		"invalid preview": {
+			"-feat_1.preview.example.com",
+			"/",
+			http.StatusNotFound,
+			"",
+			"noindex",
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			request.Host = tc.host
+
+			writer := httptest.NewRecorder()
+			instance.Handler().ServeHTTP(writer, request)
+
+			if result := writer.Code; result != tc.wantStatus {
+				t.Errorf("Status %d, want %d", result, tc.wantStatus)
+			}
+
+			if len(tc.wantBody) != 0 {
+				if result := writer.Body.String(); result != tc.wantBody {
+					t.Errorf("Body `%s`, want `%s`", result, tc.wantBody)
+				}
+			}
+
+			if result := writer.Header().Get(robotsTagHeader); result != tc.wantRobots {
+				t.Errorf("%s = `%s`, want `%s`", robotsTagHeader, result, tc.wantRobots)
+			}
+		})
+	}
+}
+
+func TestHandlerDev(t *testing.T) {
+	devService, err := dev.New(&dev.Config{Enabled: true}, exampleDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := New(&Config{Directory: exampleDir}, nil, nil, devService, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		path       string
+		wantScript bool
+	}{
+		"html": {
+			"/",
+			true,
+		},
+		"asset": {
+			"/index.js",
+			false,
+		},
+	}
+
+	for intention, tc := range cases {
+		t.Run(intention, func(t *testing.T) {
+			// An ETag valid outside of development mode must not produce a 304
+			production := httptest.NewRecorder()
+			App{directory: exampleDir}.Handler().ServeHTTP(production, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			request := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			request.Header.Set("If-None-Match", production.Header().Get("Etag"))
+
+			writer := httptest.NewRecorder()
+			instance.Handler().ServeHTTP(writer, request)
+
+			if result := writer.Code; result != http.StatusOK {
+				t.Errorf("Status %d, want %d", result, http.StatusOK)
+			}
+
+			if result := writer.Header().Get(cacheControlHeader); result != noStoreValue {
+				t.Errorf("%s = `%s`, want `%s`", cacheControlHeader, result, noStoreValue)
+			}
+
+			if result := writer.Header().Get("Etag"); len(result) != 0 {
+				t.Errorf("Etag = `%s`, want none", result)
+			}
+
+			if result := strings.Contains(writer.Body.String(), dev.EventsPath); result != tc.wantScript {
+				t.Errorf("script injected = %t, want %t", result, tc.wantScript)
+			}
+		})
+	}
+}
+
+func TestHandlerNotModifiedHeaders(t *testing.T) {
+	directory := writeSite(t, map[string]string{
+		indexFilename: `<link rel="preload" href="/app.css" as="style"><script>run()</script>`,
+	})
+
+	instance, err := New(&Config{
+		Directory:  directory,
+		Index:      true,
+		Headers:    []string{"content-security-policy:default-src 'self'"},
+		CspHash:    true,
+		EarlyHints: true,
+	}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Early Hints are final for a recorder, only the headers are checked here
+	newRequest := func() *http.Request {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.ProtoMinor = 0
+
+		return request
+	}
+
+	writer := httptest.NewRecorder()
+	instance.Handler().ServeHTTP(writer, newRequest())
+
+	wantCSP := writer.Header().Get(cspHeader)
+	if !strings.Contains(wantCSP, "'sha256-") {
+		t.Fatalf("%s = `%s`, want inline hashes", cspHeader, wantCSP)
+	}
+
+	wantLink := writer.Header().Values(linkHeader)
+	if len(wantLink) == 0 {
+		t.Fatalf("%s is empty, want preload", linkHeader)
+	}
+
+	request := newRequest()
+	request.Header.Set("If-None-Match", writer.Header().Get("Etag"))
+
+	writer = httptest.NewRecorder()
+	instance.Handler().ServeHTTP(writer, request)
+
+	if writer.Code != http.StatusNotModified {
+		t.Errorf("Status %d, want %d", writer.Code, http.StatusNotModified)
+	}
+
+	if result := writer.Header().Get(cspHeader); result != wantCSP {
+		t.Errorf("%s = `%s`, want `%s`", cspHeader, result, wantCSP)
+	}
+
+	if result := writer.Header().Values(linkHeader); !reflect.DeepEqual(result, wantLink) {
+		t.Errorf("%s = %q, want %q", linkHeader, result, wantLink)
+	}
+}